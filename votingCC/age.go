@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Returns an attestation of the user being at least minAge years old by the
+// end of the election, if a registrar has submitted one.
+func (s *VotingChaincode) findAgeAttestation(stub shim.ChaincodeStubInterface, ssn string, minAge int, endDate string) (*AgeAttestation, error) {
+	attestationIterator, err := stub.GetStateByPartialCompositeKey(c.AGE_ATTESTED, []string{ssn})
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_04", []string{err.Error()}))
+	}
+	defer attestationIterator.Close()
+
+	for attestationIterator.HasNext() {
+		record, err := attestationIterator.Next()
+		if err != nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+		}
+
+		attestation := AgeAttestation{}
+		json.Unmarshal(record.Value, &attestation)
+
+		if attestation.MinAge >= minAge && !u.IsAfter(attestation.Date, endDate, "2006/01/02") {
+			return &attestation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Returns the age shown for the user and whether the user is old enough for
+// the election. A registrar attestation is used when there is one, the date
+// of birth otherwise.
+func (s *VotingChaincode) checkAge(stub shim.ChaincodeStubInterface, user User, startDate, endDate string, minAge int) (string, bool, error) {
+	attestation, err := s.findAgeAttestation(stub, user.SSN, minAge, endDate)
+	if err != nil {
+		return "", false, err
+	}
+
+	if attestation != nil {
+		return strconv.Itoa(attestation.MinAge) + "+", true, nil
+	}
+
+	if user.DateOfBirth == "" {
+		return "", false, errors.New(msg.GetErrMsg("VOT_ERR_52", []string{user.SSN, fmt.Sprint("no date of birth or attestation of " + strconv.Itoa(minAge) + "+")}))
+	}
+
+	age, isEligible := u.ValidateAge(user.DateOfBirth, "2006/01/02", startDate, endDate, minAge)
+
+	return age, isEligible, nil
+}
+
+// args[0] : ssn
+// args[1] : min age
+// args[2] : date [yyyy/mm/dd, the user is at least min age by this date]
+// args[3] : registrar account
+// args[4] : R [signature of args[0..3] by the registrar]
+// args[5] : S
+// args[6] : X
+// args[7] : Y
+// @notice the registrar account must be enrolled by the submitter, see enrollRegistrarKey
+func (s *VotingChaincode) attestAge(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 8 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"attestAge", "8"}))
+	}
+
+	ssn := args[0]
+	date := args[2]
+	registrar := args[3]
+	R := args[4]
+	S := args[5]
+
+	minAge, err := strconv.Atoi(args[1])
+	if err != nil || minAge < 1 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_31", []string{fmt.Sprint("min age " + args[1])}))
+	}
+
+	_, err = time.Parse("2006/01/02", date)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_31", []string{fmt.Sprint("date " + date)}))
+	}
+
+	found, _ := u.FindUserBySSN(stub, ssn)
+	if !found {
+		return shim.Error(msg.GetErrMsg("COM_ERR_14", []string{ssn}))
+	}
+
+	registrarKey, err := s.verifyRegistrar(stub, registrar, args[:4], R, S, args[6], args[7])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	attestationDate := now.Format("2006/01/02 15:04:05")
+	attestation := AgeAttestation{ssn, minAge, date, registrar, registrarKey.MSPID, registrarKey.Subject, R, S, attestationDate, stub.GetTxID()}
+
+	attestationAsBytes, _ := json.Marshal(attestation)
+	err = u.PutCompKey(stub, c.AGE_ATTESTED, []string{ssn, strconv.Itoa(minAge), date}, attestationAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(attestationAsBytes)
+}
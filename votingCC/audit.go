@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Calls of these roles are recorded in the audit log
+var administrativeRoles = []string{c.ADMIN, c.REGISTRAR, c.OFFICIAL}
+
+func isAdministrative(stub shim.ChaincodeStubInterface) bool {
+	role := u.GetRole(stub)
+
+	for _, administrative := range administrativeRoles {
+		if role == administrative {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Appends the call to the audit log under AUDIT_ENTRY, keyed by date so the
+// log is listed in order. The date is the transaction timestamp of the
+// client, every endorser writes the same entry.
+func (s *VotingChaincode) logAudit(stub shim.ChaincodeStubInterface, function string, args []string) error {
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_26", []string{err.Error()}))
+	}
+
+	now := time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC()
+	entry := AuditEntry{
+		Function: function,
+		Args:     args,
+		Role:     u.GetRole(stub),
+		MSPID:    mspID,
+		Subject:  subject,
+		Date:     now.Format("2006/01/02 15:04:05"),
+		TxID:     stub.GetTxID()}
+
+	entryAsBytes, _ := json.Marshal(entry)
+
+	return u.PutCompKey(stub, c.AUDIT_ENTRY, []string{now.Format("2006/01/02"), now.Format("15:04:05"), stub.GetTxID()}, entryAsBytes)
+}
+
+// args[0] : bookmark [empty for the first page]
+// args[1] : page size
+// args[2] : date [optional, 2006/01/02, every date when empty]
+func (s *VotingChaincode) getAuditLog(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getAuditLog", "2 or 3"}))
+	}
+
+	pageSize, err := u.GetPageSize(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	keys := []string{}
+	if len(args) == 3 && args[2] != "" {
+		keys = append(keys, args[2])
+	}
+
+	page, err := u.GetCompositeKeyPage(stub, c.AUDIT_ENTRY, keys, pageSize, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	auditLog := AuditLog{make([]AuditEntry, 0), page.FetchedRecordsCount, page.Bookmark}
+	for _, record := range page.Records {
+		entry := AuditEntry{}
+		json.Unmarshal([]byte(record.Value), &entry)
+
+		auditLog.Entries = append(auditLog.Entries, entry)
+	}
+
+	auditLogAsBytes, _ := json.Marshal(auditLog)
+
+	return shim.Success(auditLogAsBytes)
+}
+
+// args[0] : election type
+// args[1] : bookmark [empty for the first page]
+// args[2] : page size
+// @notice pages are taken over every account, so a page may hold fewer voters than the page size
+func (s *VotingChaincode) getVoterRoll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getVoterRoll", "3"}))
+	}
+
+	electionType := args[0]
+
+	pageSize, err := u.GetPageSize(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	dataIterator, metadata, err := stub.GetStateByRangeWithPagination("", "", pageSize, args[1])
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_16", []string{err.Error()}))
+	}
+	defer dataIterator.Close()
+
+	voterRoll := VoterRoll{ElectionType: electionType, Voters: make([]User, 0)}
+
+	for dataIterator.HasNext() {
+		record, err := dataIterator.Next()
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+		}
+
+		// @notice accounts share the range with plain keys such as the election quorum
+		user := User{}
+		if json.Unmarshal(record.Value, &user) != nil || user.SSN == "" {
+			continue
+		}
+
+		if isRegisteredFor(user, electionType) {
+			voterRoll.Voters = append(voterRoll.Voters, user)
+		}
+	}
+
+	if metadata != nil {
+		voterRoll.FetchedRecordsCount = metadata.FetchedRecordsCount
+		voterRoll.Bookmark = metadata.Bookmark
+	}
+
+	voterRollAsBytes, _ := json.Marshal(voterRoll)
+
+	return shim.Success(voterRollAsBytes)
+}
+
+// args[0] : election type
+// args[1] : bookmark [empty for the first page]
+// args[2] : page size
+// @notice ballots in ballot log order, together with the ballot records of elect_cc
+func (s *VotingChaincode) getBallots(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getBallots", "3"}))
+	}
+
+	_, err := u.GetPageSize(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ballots, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"getBallots", args[0], args[1], args[2]})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(ballots)
+}
+
+// args[0] : chaincode [voting_cc / elect_cc]
+// args[1] : object type [composite key, e.g. electionType~ssn]
+// args[2] : attributes [json array the keys start with, empty for every key]
+// args[3] : bookmark [empty for the first page]
+// args[4] : page size
+func (s *VotingChaincode) listCompositeKeys(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 5 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"listCompositeKeys", "5"}))
+	}
+
+	chaincode := args[0]
+
+	pageSize, err := u.GetPageSize(args[4])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if chaincode == c.CCNAME {
+		page, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, append([]string{"listCompositeKeys"}, args[1:]...))
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+		}
+
+		return shim.Success(page)
+	}
+
+	if chaincode != c.VOTING_CCNAME {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{chaincode, "expecting " + c.VOTING_CCNAME + " or " + c.CCNAME}))
+	}
+
+	attributes := []string{}
+	if args[2] != "" {
+		err = json.Unmarshal([]byte(args[2]), &attributes)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+		}
+	}
+
+	page, err := u.GetCompositeKeyPage(stub, args[1], attributes, pageSize, args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pageAsBytes, _ := json.Marshal(page)
+
+	return shim.Success(pageAsBytes)
+}
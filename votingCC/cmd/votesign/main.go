@@ -0,0 +1,426 @@
+// Command votesign prepares signed arguments for the voting chaincode offline,
+// so private keys never leave the voter's machine.
+//
+//	votesign keys      [-alg p256]
+//	votesign account   -pub KEY [-alg p256]
+//	votesign candidate -election TYPE -priv KEY -pub KEY [-alg p256]
+//	votesign rotate    -priv KEY -pub KEY -new KEY [-alg p256] [-newalg ALG]
+//	votesign delegate  -election TYPE -proxy SSN -priv KEY -pub KEY [-alg p256] [-revoke]
+//	votesign petition  -election TYPE -candidate SSN -priv KEY -pub KEY [-alg p256] [-endorse]
+//	votesign vote      -ssn SSN -election TYPE -candidate ACCOUNT [-mode open] [-proxy SSN] [-id ID] [-key KEY] [-candidates JSON] [-priv KEY] [-ring JSON]
+//	votesign verify    -data DATA -r R -s S (-pub KEY | -x X -y Y) [-account ACCOUNT] [-alg p256]
+//
+// candidate, rotate, delegate, petition and vote print the invoke arguments as {"Args":[...]},
+// ready for peer chaincode invoke -c. Signatures cover the function name and
+// the arguments before them, see GetSignedPayload. Secret ballots also print
+// the opening for verifyMyVote to stderr; keep it, it cannot be recovered.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	a "../../utils/access"
+	c "../../utils/constants"
+)
+
+type invoke struct {
+	Args []string `json:"Args"`
+}
+
+type opening struct {
+	Candidate  string   `json:"Candidate,omitempty"`
+	Salt       string   `json:"Salt,omitempty"`
+	Randomness []string `json:"Randomness,omitempty"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fail(errors.New("expected keys, account, candidate, rotate, delegate, petition, vote or verify"))
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "keys":
+		err = keys(os.Args[2:])
+	case "account":
+		err = account(os.Args[2:])
+	case "candidate":
+		err = candidate(os.Args[2:])
+	case "rotate":
+		err = rotate(os.Args[2:])
+	case "delegate":
+		err = delegate(os.Args[2:])
+	case "petition":
+		err = petition(os.Args[2:])
+	case "vote":
+		err = vote(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		err = errors.New("unknown command " + os.Args[1])
+	}
+
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "votesign:", err)
+	os.Exit(1)
+}
+
+func printJSON(val interface{}) {
+	valAsBytes, _ := json.Marshal(val)
+	fmt.Println(string(valAsBytes))
+}
+
+func printInvoke(args ...string) {
+	printJSON(invoke{args})
+}
+
+func printOpening(val opening) {
+	valAsBytes, _ := json.Marshal(val)
+	fmt.Fprintln(os.Stderr, "opening:", string(valAsBytes))
+}
+
+// Signs the call and prints its arguments followed by R, S, X, Y
+func printSigned(algorithm, privKey, pubKey string, args ...string) error {
+	signature, err := sign(algorithm, privKey, pubKey, a.GetSignedPayload(args[0], args[1:]))
+	if err != nil {
+		return err
+	}
+
+	printInvoke(append(args, signature...)...)
+
+	return nil
+}
+
+// Returns the scheme and the normalized public key
+func getKey(algorithm, pubKey string) (a.Scheme, string, error) {
+	scheme, err := a.GetScheme(algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+
+	normalized := scheme.NormalizePublicKey(pubKey)
+	if normalized == "" {
+		return nil, "", errors.New("invalid " + algorithm + " public key")
+	}
+
+	return scheme, normalized, nil
+}
+
+// Signs the data and returns R, S, X, Y as the chaincode expects them
+func sign(algorithm, privKey, pubKey, data string) ([]string, error) {
+	scheme, pubKey, err := getKey(algorithm, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := scheme.Sign(privKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// @notice catches a private key that does not belong to the public key before the chaincode does
+	if !scheme.Verify(pubKey, data, *signature) {
+		return nil, errors.New("private key does not match the public key")
+	}
+
+	coordinates, err := scheme.GetCoordinates(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{signature.R, signature.S, coordinates.X, coordinates.Y}, nil
+}
+
+func keys(args []string) error {
+	flags := flag.NewFlagSet("keys", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm [p256 / ed25519 / secp256k1]")
+	flags.Parse(args)
+
+	scheme, err := a.GetScheme(*algorithm)
+	if err != nil {
+		return err
+	}
+
+	generated, err := scheme.GenerateKeys()
+	if err != nil {
+		return err
+	}
+
+	printJSON(map[string]string{
+		"algorithm":  *algorithm,
+		"privateKey": generated.PrivateKey,
+		"publicKey":  generated.PublicKey,
+		"account":    a.GenerateAccount(generated.PublicKey)})
+
+	return nil
+}
+
+func account(args []string) error {
+	flags := flag.NewFlagSet("account", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm")
+	pubKey := flags.String("pub", "", "public key")
+	flags.Parse(args)
+
+	_, normalized, err := getKey(*algorithm, *pubKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(a.GenerateAccount(normalized))
+
+	return nil
+}
+
+// registerCandidate takes a signature of the candidate key
+func candidate(args []string) error {
+	flags := flag.NewFlagSet("candidate", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm of the account")
+	electionType := flags.String("election", "", "election type [primary / general / local]")
+	privKey := flags.String("priv", "", "private key")
+	pubKey := flags.String("pub", "", "public key")
+	flags.Parse(args)
+
+	if *electionType == "" {
+		return errors.New("-election is required")
+	}
+
+	_, normalized, err := getKey(*algorithm, *pubKey)
+	if err != nil {
+		return err
+	}
+
+	return printSigned(*algorithm, *privKey, *pubKey, "registerCandidate", *electionType, a.GenerateAccount(normalized))
+}
+
+// rotateKey takes a signature of the new public key by the current key
+func rotate(args []string) error {
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm of the current account")
+	privKey := flags.String("priv", "", "current private key")
+	pubKey := flags.String("pub", "", "current public key")
+	newPubKey := flags.String("new", "", "new public key")
+	newAlgorithm := flags.String("newalg", "", "signature algorithm of the new key [optional, p256 by default]")
+	flags.Parse(args)
+
+	_, _, err := getKey(*newAlgorithm, *newPubKey)
+	if err != nil {
+		return err
+	}
+
+	_, normalized, err := getKey(*algorithm, *pubKey)
+	if err != nil {
+		return err
+	}
+
+	invokeArgs := []string{"rotateKey", a.GenerateAccount(normalized), *newPubKey}
+	if *newAlgorithm != "" {
+		invokeArgs = append(invokeArgs, *newAlgorithm)
+	}
+
+	return printSigned(*algorithm, *privKey, *pubKey, invokeArgs...)
+}
+
+// delegateVote and revokeDelegation take a signature of the delegator
+func delegate(args []string) error {
+	flags := flag.NewFlagSet("delegate", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm of the account")
+	electionType := flags.String("election", "", "election type [primary / general / local]")
+	proxySSN := flags.String("proxy", "", "proxy ssn")
+	privKey := flags.String("priv", "", "private key")
+	pubKey := flags.String("pub", "", "public key")
+	revoke := flags.Bool("revoke", false, "revoke the delegation to the proxy")
+	flags.Parse(args)
+
+	if *electionType == "" || *proxySSN == "" {
+		return errors.New("-election and -proxy are required")
+	}
+
+	_, normalized, err := getKey(*algorithm, *pubKey)
+	if err != nil {
+		return err
+	}
+	account := a.GenerateAccount(normalized)
+
+	if *revoke {
+		return printSigned(*algorithm, *privKey, *pubKey, "revokeDelegation", account, *electionType)
+	}
+
+	return printSigned(*algorithm, *privKey, *pubKey, "delegateVote", account, *electionType, *proxySSN)
+}
+
+// openPetition takes the candidate's signature, endorsePetition a voter's
+func petition(args []string) error {
+	flags := flag.NewFlagSet("petition", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm of the account")
+	electionType := flags.String("election", "", "election type [primary / general / local]")
+	candidateSSN := flags.String("candidate", "", "candidate ssn")
+	privKey := flags.String("priv", "", "private key")
+	pubKey := flags.String("pub", "", "public key")
+	endorse := flags.Bool("endorse", false, "endorse the petition of the candidate")
+	flags.Parse(args)
+
+	if *electionType == "" || *candidateSSN == "" {
+		return errors.New("-election and -candidate are required")
+	}
+
+	_, normalized, err := getKey(*algorithm, *pubKey)
+	if err != nil {
+		return err
+	}
+	account := a.GenerateAccount(normalized)
+
+	if *endorse {
+		return printSigned(*algorithm, *privKey, *pubKey, "endorsePetition", *electionType, *candidateSSN, account)
+	}
+
+	return printSigned(*algorithm, *privKey, *pubKey, "openPetition", *electionType, account)
+}
+
+// vote builds the ballot argument for the ballot mode of the election
+func vote(args []string) error {
+	flags := flag.NewFlagSet("vote", flag.ExitOnError)
+	mode := flags.String("mode", c.OPEN, "ballot mode [open / commit-reveal / homomorphic / ecies / ring-signature]")
+	ssn := flags.String("ssn", "", "voter ssn [not needed for ring-signature]")
+	electionType := flags.String("election", "", "election type [primary / general / local]")
+	electionID := flags.String("id", "", "election ID [commit-reveal / homomorphic / ecies / ring-signature]")
+	proxySSN := flags.String("proxy", "", "voting as the proxy of the -ssn voter [open]")
+	candidateKey := flags.String("candidate", "", "candidate account")
+	electionKey := flags.String("key", "", "election public key [homomorphic / ecies]")
+	candidates := flags.String("candidates", "", "getBallotCandidates result [homomorphic]")
+	privKey := flags.String("priv", "", "voter P-256 private key [ring-signature]")
+	ring := flags.String("ring", "", "getRing result [ring-signature]")
+	flags.Parse(args)
+
+	if *electionType == "" || *candidateKey == "" {
+		return errors.New("-election and -candidate are required")
+	}
+
+	// @notice same as getBallotContext in the chaincode
+	context := *electionType + c.SEPARATOR + *electionID
+
+	switch *mode {
+	case c.OPEN:
+		if *proxySSN != "" {
+			printInvoke("vote", *ssn, *electionType, *candidateKey, *proxySSN)
+		} else {
+			printInvoke("vote", *ssn, *electionType, *candidateKey)
+		}
+
+	case c.COMMIT_REVEAL:
+		saltBytes := make([]byte, 16)
+		if _, err := rand.Read(saltBytes); err != nil {
+			return err
+		}
+		salt := hex.EncodeToString(saltBytes)
+
+		printInvoke("vote", *ssn, *electionType, a.GetCommitment(context, *ssn, *candidateKey, salt))
+		printOpening(opening{Candidate: *candidateKey, Salt: salt})
+
+	case c.HOMOMORPHIC:
+		var accounts []string
+		if err := json.Unmarshal([]byte(*candidates), &accounts); err != nil {
+			return errors.New("-candidates : " + err.Error())
+		}
+
+		choice := -1
+		for i, account := range accounts {
+			if account == *candidateKey {
+				choice = i
+			}
+		}
+
+		if choice < 0 {
+			return errors.New("candidate is not on the ballot")
+		}
+
+		ballot, randomness, err := a.EncryptBallot(*electionKey, len(accounts), choice, context)
+		if err != nil {
+			return err
+		}
+
+		ballotAsBytes, _ := json.Marshal(ballot)
+
+		printInvoke("vote", *ssn, *electionType, string(ballotAsBytes))
+		printOpening(opening{Randomness: randomness})
+
+	case c.ECIES:
+		sealed, randomness, err := a.SealChoice(*electionKey, *candidateKey, context)
+		if err != nil {
+			return err
+		}
+
+		printInvoke("vote", *ssn, *electionType, sealed)
+		printOpening(opening{Randomness: []string{randomness}})
+
+	case c.RING:
+		var members []string
+		if err := json.Unmarshal([]byte(*ring), &members); err != nil {
+			return errors.New("-ring : " + err.Error())
+		}
+
+		signature, err := a.SignRing(*privKey, members, *candidateKey, context)
+		if err != nil {
+			return err
+		}
+
+		signatureAsBytes, _ := json.Marshal(signature)
+
+		printInvoke("castRingBallot", *electionType, string(signatureAsBytes), *candidateKey)
+
+	default:
+		return errors.New("unsupported ballot mode " + *mode)
+	}
+
+	return nil
+}
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	algorithm := flags.String("alg", a.P256, "signature algorithm")
+	data := flags.String("data", "", "signed data [the JSON array of the function and its arguments for chaincode calls]")
+	R := flags.String("r", "", "R")
+	S := flags.String("s", "", "S")
+	pubKey := flags.String("pub", "", "public key")
+	X := flags.String("x", "", "X")
+	Y := flags.String("y", "", "Y")
+	accountKey := flags.String("account", "", "account the key must belong to [optional]")
+	flags.Parse(args)
+
+	scheme, err := a.GetScheme(*algorithm)
+	if err != nil {
+		return err
+	}
+
+	key := scheme.NormalizePublicKey(*pubKey)
+	if *pubKey == "" {
+		key = scheme.GetPublicKey(*X, *Y)
+	}
+
+	if key == "" {
+		return errors.New("invalid " + *algorithm + " public key")
+	}
+
+	if *accountKey != "" && !a.IsAccountOf(*accountKey, key) {
+		return errors.New("public key does not belong to account " + *accountKey)
+	}
+
+	if !scheme.Verify(key, *data, a.Signature{R: *R, S: *S}) {
+		return errors.New("invalid signature")
+	}
+
+	fmt.Println("valid")
+
+	return nil
+}
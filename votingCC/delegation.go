@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+func (s *VotingChaincode) findDelegation(stub shim.ChaincodeStubInterface, electionType, delegatorSSN string) (string, *Delegation, error) {
+	delegationKey, err := stub.CreateCompositeKey(c.DELEGATION, []string{electionType, delegatorSSN})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.DELEGATION, delegatorSSN, err.Error()}))
+	}
+
+	delegationAsBytes, err := stub.GetState(delegationKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{delegationKey, err.Error()}))
+	}
+
+	if delegationAsBytes == nil {
+		return delegationKey, nil, nil
+	}
+
+	delegation := Delegation{}
+	err = json.Unmarshal(delegationAsBytes, &delegation)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return delegationKey, &delegation, nil
+}
+
+// Checks whether the voter holds active delegations of other voters
+func (s *VotingChaincode) isProxy(stub shim.ChaincodeStubInterface, electionType, ssn string) (bool, error) {
+	delegatorKeys, err := u.GetAllCompositeKeys(stub, c.PROXY, []string{electionType, ssn})
+	if err != nil {
+		return false, err
+	}
+
+	for _, delegatorKey := range delegatorKeys {
+		_, keyParts, err := stub.SplitCompositeKey(delegatorKey)
+		if err != nil {
+			return false, errors.New(msg.GetErrMsg("COM_ERR_07", []string{delegatorKey}))
+		}
+
+		_, delegation, err := s.findDelegation(stub, electionType, keyParts[2])
+		if err != nil {
+			return false, err
+		}
+
+		if delegation != nil && delegation.Status == c.ACTIVE {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *VotingChaincode) putDelegation(stub shim.ChaincodeStubInterface, delegationKey string, delegation *Delegation) error {
+	delegationAsBytes, _ := json.Marshal(delegation)
+
+	err := stub.PutState(delegationKey, delegationAsBytes)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_09", []string{delegationKey, err.Error()}))
+	}
+
+	return nil
+}
+
+// Closes the active delegation of the voter, if there is one, once a ballot
+// is cast for the voter. A vote in person revokes the delegation.
+func (s *VotingChaincode) closeDelegation(stub shim.ChaincodeStubInterface, electionType, voterSSN, status string) error {
+	delegationKey, delegation, err := s.findDelegation(stub, electionType, voterSSN)
+	if err != nil {
+		return err
+	}
+
+	if delegation == nil || delegation.Status != c.ACTIVE {
+		return nil
+	}
+
+	delegation.Status = status
+	delegation.ClosingTxID = stub.GetTxID()
+
+	return s.putDelegation(stub, delegationKey, delegation)
+}
+
+// Returns the proxy once the delegation of the voter to the proxy is active.
+// The proxy, not the delegator, must be authorized by the submitter identity,
+// so a proxy without a bound identity cannot vote.
+func (s *VotingChaincode) getProxy(stub shim.ChaincodeStubInterface, electionType, delegatorSSN, proxySSN string) (User, error) {
+	_, delegation, err := s.findDelegation(stub, electionType, delegatorSSN)
+	if err != nil {
+		return User{}, err
+	}
+
+	if delegation == nil || delegation.ProxySSN != proxySSN {
+		return User{}, errors.New(msg.GetErrMsg("VOT_ERR_45", []string{delegatorSSN, proxySSN}))
+	}
+
+	if delegation.Status != c.ACTIVE {
+		return User{}, errors.New(msg.GetErrMsg("VOT_ERR_44", []string{delegatorSSN, electionType, delegation.Status}))
+	}
+
+	_, proxy, err := s.findUser(stub, proxySSN)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = checkVerified(proxy)
+	if err != nil {
+		return User{}, err
+	}
+
+	if proxy.MSPID == "" {
+		return User{}, errors.New(msg.GetErrMsg("VOT_ERR_57", []string{proxySSN}))
+	}
+
+	err = s.checkIdentity(stub, proxy)
+	if err != nil {
+		return User{}, err
+	}
+
+	return proxy, nil
+}
+
+// args[0] : delegator account
+// args[1] : election type
+// args[2] : proxy ssn [a voter registered for the same election, bound to an identity]
+// args[3] : R [signature of args[0..2] by the delegator]
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+// @notice one delegation per voter and election, a revoked delegation is not renewed
+func (s *VotingChaincode) delegateVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"delegateVote", "7"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	account := args[0]
+	electionType := args[1]
+	proxySSN := args[2]
+	R := args[3]
+	S := args[4]
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:3], R, S, args[5], args[6])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.OPEN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"delegateVote", electionInfo.BallotMode, electionType}))
+	}
+
+	if u.IsAfter(todayDate, keyParts[2], "2006/01/02") {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_13", []string{todayDate, electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2])}))
+	}
+
+	delegator, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// @notice the delegator must still be able to vote
+	_, _, _, err = s.getEligibleVoter(stub, delegator.SSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isRegisteredFor(delegator, electionType) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Voter " + delegator.SSN + " Not Registered for " + electionType)}))
+	}
+
+	if proxySSN == delegator.SSN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_45", []string{delegator.SSN, proxySSN}))
+	}
+
+	_, proxy, err := s.findUser(stub, proxySSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = checkVerified(proxy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isRegisteredFor(proxy, electionType) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Proxy " + proxySSN + " Not Registered for " + electionType)}))
+	}
+
+	// @notice the proxy votes with its own identity, see getProxy
+	if proxy.MSPID == "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_57", []string{proxySSN}))
+	}
+
+	// @notice no chains, a proxy cannot delegate and a delegator cannot be a proxy
+	isDelegatorProxy, err := s.isProxy(stub, electionType, delegator.SSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, proxyDelegation, err := s.findDelegation(stub, electionType, proxySSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if isDelegatorProxy || (proxyDelegation != nil && proxyDelegation.Status == c.ACTIVE) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_46", []string{delegator.SSN, proxySSN}))
+	}
+
+	delegationKey, existing, err := s.findDelegation(stub, electionType, delegator.SSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if existing != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_44", []string{delegator.SSN, electionType, existing.Status}))
+	}
+
+	delegationDate := now.Format("2006/01/02 15:04:05")
+	delegation := Delegation{
+		ElectionType:   electionType,
+		DelegatorSSN:   delegator.SSN,
+		ProxySSN:       proxySSN,
+		Status:         c.ACTIVE,
+		R:              R,
+		S:              S,
+		DelegationDate: delegationDate,
+		TxID:           stub.GetTxID()}
+
+	err = s.putDelegation(stub, delegationKey, &delegation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = u.CreateCompKey(stub, c.PROXY, []string{electionType, proxySSN, delegator.SSN})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	delegationAsBytes, _ := json.Marshal(delegation)
+
+	return shim.Success(delegationAsBytes)
+}
+
+// args[0] : delegator account
+// args[1] : election type
+// args[2] : R [signature of args[0..1] by the delegator]
+// args[3] : S
+// args[4] : X
+// args[5] : Y
+// @notice only before the proxy has used the delegation
+func (s *VotingChaincode) revokeDelegation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"revokeDelegation", "6"}))
+	}
+
+	account := args[0]
+	electionType := args[1]
+	R := args[2]
+	S := args[3]
+
+	delegator, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	delegationKey, delegation, err := s.findDelegation(stub, electionType, delegator.SSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if delegation == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_45", []string{delegator.SSN, ""}))
+	}
+
+	if delegation.Status != c.ACTIVE {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_44", []string{delegator.SSN, electionType, delegation.Status}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:2], R, S, args[4], args[5])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	delegation.Status = c.DELEGATION_REVOKED
+	delegation.ClosingTxID = stub.GetTxID()
+
+	err = s.putDelegation(stub, delegationKey, delegation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	delegationAsBytes, _ := json.Marshal(delegation)
+
+	return shim.Success(delegationAsBytes)
+}
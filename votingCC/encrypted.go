@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Zero-knowledge proofs on encrypted ballots and decryption shares are bound
+// to this context so they cannot be replayed in another election.
+func getBallotContext(election Election) string {
+	return fmt.Sprint(election.ElectionType + c.SEPARATOR + election.ID)
+}
+
+// Returns candidate accounts in ballot order, i.e. the order of the
+// candidate composite keys.
+func (s *VotingChaincode) getCandidateAccounts(stub shim.ChaincodeStubInterface, electionType string) ([]string, error) {
+	candidateKeys, err := u.GetAllCompositeKeys(stub, c.CANDIDATE, []string{electionType})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]string, 0)
+	for _, candidateKey := range candidateKeys {
+		_, keyParts, err := stub.SplitCompositeKey(candidateKey)
+		if err != nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_07", []string{candidateKey}))
+		}
+
+		found, account := u.FindUserBySSN(stub, keyParts[1])
+		if !found {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_14", []string{keyParts[1]}))
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// The candidate list is the ballot of homomorphic elections, candidates are
+// frozen once the election starts
+func checkCandidatesOpen(electionType, startDate, todayDate string) error {
+	if !u.IsAfter(startDate, todayDate, "2006/01/02") {
+		return errors.New(msg.GetErrMsg("VOT_ERR_58", []string{electionType, startDate}))
+	}
+
+	return nil
+}
+
+// Candidates keep their account from the start of their elections until the
+// votes are counted
+func (s *VotingChaincode) checkCandidateKey(stub shim.ChaincodeStubInterface, ssn string) error {
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	for _, electionType := range []string{c.PRIMARY, c.GENERAL, c.LOCAL} {
+		candidate, err := u.FindCompositeKey(stub, c.CANDIDATE, []string{electionType, ssn})
+		if err != nil {
+			return err
+		}
+
+		if candidate == "" {
+			continue
+		}
+
+		_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+		if err != nil {
+			return err
+		}
+
+		if electionInfo.ElectionResult == "" {
+			err = checkCandidatesOpen(electionType, keyParts[1], todayDate)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// args[0] : election type
+func (s *VotingChaincode) getBallotCandidates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getBallotCandidates", "1"}))
+	}
+
+	candidates, err := s.getCandidateAccounts(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	candidatesAsBytes, _ := json.Marshal(candidates)
+
+	return shim.Success(candidatesAsBytes)
+}
+
+// @notice the election private key never reaches the ledger, the authority
+// submits D = xA for every candidate together with a proof of correct decryption
+// args[0] : election type
+// args[1] : decryption shares [json array, one per candidate in ballot order]
+func (s *VotingChaincode) decryptTally(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"decryptTally", "2"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.HOMOMORPHIC {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"decryptTally", electionInfo.BallotMode, electionType}))
+	}
+
+	if len(electionInfo.Trustees) > 0 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_23", []string{electionType}))
+	}
+
+	isElectionOver := u.IsAfter(todayDate, keyParts[2], "2006/01/02")
+	if !isElectionOver {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_17", []string{electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2]), todayDate}))
+	}
+
+	tally, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"decryptTally", electionType,
+		getBallotContext(electionInfo), electionInfo.PublicKey, args[1]})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(tally)
+}
+
+// @notice the authority private key is passed in the transient map under
+// "authorityKey" so it never reaches the ledger
+// args[0] : election type
+// args[1] : publish plaintexts [optional, true / false, false by default]
+func (s *VotingChaincode) decryptAndTally(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"decryptAndTally", "1 or 2"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+	publish := "false"
+
+	if len(args) == 2 {
+		publish = args[1]
+	}
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.ECIES {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"decryptAndTally", electionInfo.BallotMode, electionType}))
+	}
+
+	isElectionOver := u.IsAfter(todayDate, keyParts[2], "2006/01/02")
+	if !isElectionOver {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_17", []string{electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2]), todayDate}))
+	}
+
+	_, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"closeBallotLog", electionType})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	candidates, err := s.getCandidateAccounts(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	candidatesAsBytes, _ := json.Marshal(candidates)
+
+	tally, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"decryptAndTally", electionType,
+		electionInfo.PublicKey, string(candidatesAsBytes), publish})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(tally)
+}
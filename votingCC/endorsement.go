@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	msg "./utils/msg"
+)
+
+func (s *VotingChaincode) getAuditorOrgs(stub shim.ChaincodeStubInterface) ([]string, error) {
+	orgsAsBytes, err := stub.GetState(c.AUDITOR_ORGS)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{c.AUDITOR_ORGS, err.Error()}))
+	}
+
+	orgs := make([]string, 0)
+	if orgsAsBytes != nil {
+		json.Unmarshal(orgsAsBytes, &orgs)
+	}
+
+	return orgs, nil
+}
+
+// Returns the owning organization followed by the auditor organizations
+func (s *VotingChaincode) getElectionOrgs(stub shim.ChaincodeStubInterface, ownerMSP string) ([]string, error) {
+	auditorOrgs, err := s.getAuditorOrgs(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := []string{ownerMSP}
+	for _, org := range auditorOrgs {
+		if org != ownerMSP {
+			orgs = append(orgs, org)
+		}
+	}
+
+	return orgs, nil
+}
+
+// Requires peers of every organization to endorse later changes to the key
+func setEndorsement(stub shim.ChaincodeStubInterface, key string, orgs []string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("VOT_ERR_38", []string{key, err.Error()}))
+	}
+
+	err = ep.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("VOT_ERR_38", []string{key, err.Error()}))
+	}
+
+	policy, err := ep.Policy()
+	if err != nil {
+		return errors.New(msg.GetErrMsg("VOT_ERR_38", []string{key, err.Error()}))
+	}
+
+	err = stub.SetStateValidationParameter(key, policy)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("VOT_ERR_38", []string{key, err.Error()}))
+	}
+
+	return nil
+}
+
+func parseOrgs(orgsJSON string) ([]string, error) {
+	var orgs []string
+	err := json.Unmarshal([]byte(orgsJSON), &orgs)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	for _, org := range orgs {
+		if org == "" {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_18", []string{"MSP ID", org}))
+		}
+	}
+
+	return orgs, nil
+}
+
+// args[0] : election type
+// args[1] : endorsing organizations [json array of MSP IDs]
+// @notice the change itself must satisfy the current policy of the election
+func (s *VotingChaincode) setElectionEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setElectionEndorsement", "2"}))
+	}
+
+	election, _, electionInfo, err := s.findElection(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	orgs, err := parseOrgs(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(orgs) == 0 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"endorsing organizations", args[1]}))
+	}
+
+	electionInfo.EndorsingOrgs = orgs
+	electionAsBytes, _ := json.Marshal(electionInfo)
+
+	err = stub.PutState(election, electionAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{election, err.Error()}))
+	}
+
+	err = setEndorsement(stub, election, orgs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(electionAsBytes)
+}
+
+// args[0] : election type
+func (s *VotingChaincode) getElectionEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getElectionEndorsement", "1"}))
+	}
+
+	election, _, _, err := s.findElection(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	policy, err := stub.GetStateValidationParameter(election)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{election, err.Error()}))
+	}
+
+	orgs := make([]string, 0)
+	if policy != nil {
+		ep, err := statebased.NewStateEP(policy)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+		}
+
+		orgs = ep.ListOrgs()
+	}
+
+	orgsAsBytes, _ := json.Marshal(orgs)
+
+	return shim.Success(orgsAsBytes)
+}
+
+// args[0] : auditor organizations [json array of MSP IDs]
+// @notice applies to elections activated afterwards
+func (s *VotingChaincode) setAuditorOrgs(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setAuditorOrgs", "1"}))
+	}
+
+	orgs, err := parseOrgs(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	orgsAsBytes, _ := json.Marshal(orgs)
+
+	err = stub.PutState(c.AUDITOR_ORGS, orgsAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{c.AUDITOR_ORGS, err.Error()}))
+	}
+
+	return shim.Success(orgsAsBytes)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Binds the submitter client identity to the ssn, one user per identity.
+// Returns the MSP ID and certificate subject of the submitter.
+func (s *VotingChaincode) bindIdentity(stub shim.ChaincodeStubInterface, ssn string) (string, string, error) {
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return "", "", errors.New(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	identityKey, err := stub.CreateCompositeKey(c.BOUND_IDENTITY, []string{mspID, subject})
+	if err != nil {
+		return "", "", errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.BOUND_IDENTITY, mspID, err.Error()}))
+	}
+
+	boundAsBytes, err := stub.GetState(identityKey)
+	if err != nil {
+		return "", "", errors.New(msg.GetErrMsg("COM_ERR_10", []string{identityKey, err.Error()}))
+	}
+
+	if boundAsBytes != nil {
+		return "", "", errors.New(msg.GetErrMsg("ACC_ERR_06", []string{fmt.Sprint(mspID + " " + subject), string(boundAsBytes)}))
+	}
+
+	err = stub.PutState(identityKey, []byte(ssn))
+	if err != nil {
+		return "", "", errors.New(msg.GetErrMsg("COM_ERR_09", []string{identityKey, err.Error()}))
+	}
+
+	return mspID, subject, nil
+}
+
+// Rejects the call when the user is bound to a client identity other than
+// the submitter. Users that are not bound pass.
+func (s *VotingChaincode) checkIdentity(stub shim.ChaincodeStubInterface, user User) error {
+	if user.MSPID == "" {
+		return nil
+	}
+
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	if mspID != user.MSPID || subject != user.Subject {
+		return errors.New(msg.GetErrMsg("ACC_ERR_07", []string{user.SSN}))
+	}
+
+	return nil
+}
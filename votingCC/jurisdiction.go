@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Elections without a jurisdiction, including those registered before
+// jurisdictions, are national
+func getJurisdiction(election Election) string {
+	if election.Jurisdiction == "" {
+		return c.NATIONAL
+	}
+
+	return election.Jurisdiction
+}
+
+// Returns the jurisdiction attribute of the submitter, national when the
+// certificate has none
+func getCallerJurisdiction(stub shim.ChaincodeStubInterface) string {
+	jurisdiction := u.GetAttribute(stub, c.JURISDICTION_ATTRIBUTE)
+	if jurisdiction == "" {
+		return c.NATIONAL
+	}
+
+	return jurisdiction
+}
+
+// Officials only manage elections of their own jurisdiction
+func checkJurisdiction(stub shim.ChaincodeStubInterface, jurisdiction string) error {
+	if !u.HasRole(stub, c.OFFICIAL) {
+		return nil
+	}
+
+	callerJurisdiction := getCallerJurisdiction(stub)
+	if callerJurisdiction != jurisdiction {
+		return errors.New(msg.GetErrMsg("ACC_ERR_10", []string{callerJurisdiction, jurisdiction}))
+	}
+
+	return nil
+}
+
+// Checks the jurisdiction of the registered election of the type. Elections
+// still pending approval are checked by the functions handling proposals.
+func (s *VotingChaincode) checkElectionJurisdiction(stub shim.ChaincodeStubInterface, electionType string) error {
+	if !u.HasRole(stub, c.OFFICIAL) {
+		return nil
+	}
+
+	election, err := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
+	if err != nil {
+		return err
+	}
+
+	if election == "" {
+		return nil
+	}
+
+	electionInfo, err := s.getElection(stub, election)
+	if err != nil {
+		return err
+	}
+
+	return checkJurisdiction(stub, getJurisdiction(electionInfo))
+}
+
+// Voters of a national election may reside anywhere
+func isResident(user User, election Election) bool {
+	jurisdiction := getJurisdiction(election)
+
+	return jurisdiction == c.NATIONAL || user.Residence == jurisdiction
+}
+
+// args[0] : ssn
+// args[1] : jurisdiction the user resides in
+func (s *VotingChaincode) setResidence(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setResidence", "2"}))
+	}
+
+	ssn := args[0]
+	jurisdiction := args[1]
+
+	if jurisdiction == "" {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"jurisdiction", "empty"}))
+	}
+
+	found, account := u.FindUserBySSN(stub, ssn)
+	if !found {
+		return shim.Error(msg.GetErrMsg("COM_ERR_14", []string{ssn}))
+	}
+
+	userAsBytes, err := stub.GetState(account)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{account, err.Error()}))
+	}
+
+	user := User{}
+	json.Unmarshal(userAsBytes, &user)
+
+	user.Residence = jurisdiction
+	userAsBytes, _ = json.Marshal(user)
+
+	err = stub.PutState(account, userAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{account, err.Error()}))
+	}
+
+	return shim.Success(userAsBytes)
+}
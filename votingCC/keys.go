@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "./utils/access"
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Returns the current user record stored at the account
+func (s *VotingChaincode) getCurrentUser(stub shim.ChaincodeStubInterface, account string) (User, error) {
+	user := User{}
+
+	err := a.ValidateAccount(account)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_24", []string{account, err.Error()}))
+	}
+
+	userAsBytes, err := stub.GetState(account)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_10", []string{account, err.Error()}))
+	}
+
+	if userAsBytes == nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_14", []string{account}))
+	}
+
+	err = json.Unmarshal(userAsBytes, &user)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	if user.RotatedTo != "" {
+		return user, errors.New(msg.GetErrMsg("VOT_ERR_28", []string{account, user.RotatedTo}))
+	}
+
+	return user, nil
+}
+
+// Moves the user record to the account of the new public key. The old record
+// is kept as an alias pointing to the new account, the new record links back
+// to the old one and the SSN index is switched over.
+func (s *VotingChaincode) bindKey(stub shim.ChaincodeStubInterface, user User, newPubKey, algorithm string) (User, error) {
+	err := s.checkCandidateKey(stub, user.SSN)
+	if err != nil {
+		return user, err
+	}
+
+	oldAccount := user.PublicKey
+	newAccount := a.GenerateAccount(newPubKey)
+
+	existingAsBytes, _ := stub.GetState(newAccount)
+	if existingAsBytes != nil {
+		return user, errors.New(msg.GetErrMsg("VOT_ERR_30", []string{newAccount}))
+	}
+
+	err = s.moveRingMember(stub, user.SSN, newAccount, newPubKey, algorithm)
+	if err != nil {
+		return user, err
+	}
+
+	newUser := user
+	newUser.PublicKey = newAccount
+	newUser.PreviousKey = oldAccount
+	newUser.RotatedTo = ""
+	newUser.Algorithm = algorithm
+
+	newUserAsBytes, _ := json.Marshal(newUser)
+	err = stub.PutState(newAccount, newUserAsBytes)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_09", []string{newAccount, err.Error()}))
+	}
+
+	user.RotatedTo = newAccount
+	oldUserAsBytes, _ := json.Marshal(user)
+	err = stub.PutState(oldAccount, oldUserAsBytes)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_09", []string{oldAccount, err.Error()}))
+	}
+
+	ssnKey, err := stub.CreateCompositeKey(c.SSNKEY, []string{user.SSN, oldAccount})
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.SSNKEY, user.SSN, err.Error()}))
+	}
+
+	err = stub.DelState(ssnKey)
+	if err != nil {
+		return user, errors.New(msg.GetErrMsg("COM_ERR_09", []string{ssnKey, err.Error()}))
+	}
+
+	err = u.CreateCompKey(stub, c.SSNKEY, []string{user.SSN, newAccount})
+	if err != nil {
+		return user, err
+	}
+
+	return newUser, nil
+}
+
+// args[0] : current account
+// args[1] : new public key
+// args[2] : signature algorithm of the new key [optional, p256 by default]
+// args[2/3] : R [signature of args[0..1/2] by the current key]
+// args[3/4] : S
+// args[4/5] : X
+// args[5/6] : Y
+// @notice R, S, X, Y can be omitted when the transaction is submitted by an
+// election official of the jurisdiction the user resides in
+func (s *VotingChaincode) rotateKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 2 || len(args) > 7 || (len(args) > 3 && len(args) < 6) {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"rotateKey", "2, 3, 6 or 7"}))
+	}
+
+	account := args[0]
+	newPubKey := args[1]
+	algorithm := a.P256
+	signature := args[2:]
+	if len(args)%2 == 1 {
+		algorithm = args[2]
+		signature = args[3:]
+	}
+
+	scheme, err := a.GetScheme(algorithm)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"algorithm", algorithm}))
+	}
+
+	newPubKey = scheme.NormalizePublicKey(args[1])
+	if newPubKey == "" {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"public key", args[1]}))
+	}
+
+	user, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(signature) == 4 {
+		R := signature[0]
+		S := signature[1]
+
+		isVerified, hash, err := u.VerifyUser(stub, account, args[:len(args)-4], R, S, signature[2], signature[3])
+		if !isVerified {
+			return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+				" R: " + R + " S: " + S), fmt.Sprint(err)}))
+		}
+	} else if !u.HasAnyRole(stub, officials) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_29", []string{"rotateKey requires a signature of the current key or an election official"}))
+	} else {
+		// @notice national officials approve any user, the others only their residents
+		jurisdiction := getCallerJurisdiction(stub)
+		if jurisdiction != c.NATIONAL && jurisdiction != user.Residence {
+			return shim.Error(msg.GetErrMsg("ACC_ERR_12", []string{jurisdiction, user.SSN}))
+		}
+	}
+
+	newUser, err := s.bindKey(stub, user, newPubKey, algorithm)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = s.revokeAccount(stub, account, c.ROTATED)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	newUserAsBytes, _ := json.Marshal(newUser)
+
+	return shim.Success(newUserAsBytes)
+}
+
+// Stores the revocation record that makes VerifyUser reject the account
+func (s *VotingChaincode) revokeAccount(stub shim.ChaincodeStubInterface, account, reason string) (Revocation, error) {
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return Revocation{}, err
+	}
+
+	// @notice the date is part of the key, endorsing peers must agree on it
+	revocationDate := now.Format("2006/01/02 15:04:05")
+	revocation := Revocation{account, reason, revocationDate, stub.GetTxID()}
+
+	revocationAsBytes, _ := json.Marshal(revocation)
+	err = u.PutCompKey(stub, c.REVOKED_KEY, []string{account, revocationDate}, revocationAsBytes)
+	if err != nil {
+		return revocation, err
+	}
+
+	return revocation, nil
+}
+
+// args[0] : account
+// args[1] : reason
+// args[2] : R [signature of args[0..1] by the revoked key]
+// args[3] : S
+// args[4] : X
+// args[5] : Y
+// @notice R, S, X, Y can be omitted when the transaction is submitted by a registrar
+func (s *VotingChaincode) revokeKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"revokeKey", "2 or 6"}))
+	}
+
+	account := args[0]
+	reason := args[1]
+
+	if reason == "" {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"reason", reason}))
+	}
+
+	if len(args) == 6 {
+		R := args[2]
+		S := args[3]
+		X := args[4]
+		Y := args[5]
+
+		isVerified, hash, err := u.VerifyUser(stub, account, args[:2], R, S, X, Y)
+		if !isVerified {
+			return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+				" R: " + R + " S: " + S), fmt.Sprint(err)}))
+		}
+	} else if !u.HasAnyRole(stub, registrars) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_29", []string{"revokeKey requires a signature of the key or a registrar"}))
+	}
+
+	if u.IsRevoked(stub, account) {
+		return shim.Error(msg.GetErrMsg("COM_ERR_23", []string{account}))
+	}
+
+	_, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	revocation, err := s.revokeAccount(stub, account, reason)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	revocationAsBytes, _ := json.Marshal(revocation)
+
+	return shim.Success(revocationAsBytes)
+}
+
+// args[0] : ssn
+// args[1] : new public key [generated by the user]
+// args[2] : signature algorithm of the new key [optional, algorithm of the current key by default]
+// @notice the current key is revoked if it was not already and the old record
+// is kept as audit history. The private key never reaches the ledger.
+func (s *VotingChaincode) recoverKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"recoverKey", "2 or 3"}))
+	}
+
+	ssn := args[0]
+
+	found, account := u.FindUserBySSN(stub, ssn)
+	if !found {
+		return shim.Error(msg.GetErrMsg("COM_ERR_14", []string{ssn}))
+	}
+
+	user, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	algorithm := user.Algorithm
+	if len(args) == 3 {
+		algorithm = args[2]
+	}
+
+	if algorithm == "" {
+		algorithm = a.P256
+	}
+
+	scheme, err := a.GetScheme(algorithm)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"algorithm", algorithm}))
+	}
+
+	pubKey := scheme.NormalizePublicKey(args[1])
+	if pubKey == "" {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"public key", args[1]}))
+	}
+
+	newUser, err := s.bindKey(stub, user, pubKey, algorithm)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !u.IsRevoked(stub, account) {
+		_, err = s.revokeAccount(stub, account, c.RECOVERED)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	newUserAsBytes, _ := json.Marshal(newUser)
+
+	return shim.Success(newUserAsBytes)
+}
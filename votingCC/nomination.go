@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+func (s *VotingChaincode) findPetition(stub shim.ChaincodeStubInterface, electionType, candidateSSN string) (string, *Petition, error) {
+	petitionKey, err := stub.CreateCompositeKey(c.PETITION, []string{electionType, candidateSSN})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.PETITION, candidateSSN, err.Error()}))
+	}
+
+	petitionAsBytes, err := stub.GetState(petitionKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{petitionKey, err.Error()}))
+	}
+
+	if petitionAsBytes == nil {
+		return petitionKey, nil, nil
+	}
+
+	petition := Petition{}
+	err = json.Unmarshal(petitionAsBytes, &petition)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return petitionKey, &petition, nil
+}
+
+// Open petitions past the nomination deadline are reported as expired
+func getPetitionStatus(petition Petition, todayDate string) string {
+	if petition.Status == c.PETITION_OPEN && u.IsAfter(todayDate, petition.Deadline, "2006/01/02") {
+		return c.EXPIRED
+	}
+
+	return petition.Status
+}
+
+// Returns the registered election of the type once it nominates candidates
+// by petition and the nomination deadline has not passed
+func (s *VotingChaincode) getNominatingElection(stub shim.ChaincodeStubInterface, electionType, todayDate string) ([]string, Election, error) {
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return nil, electionInfo, err
+	}
+
+	if electionInfo.NominationThreshold == 0 {
+		return nil, electionInfo, errors.New(msg.GetErrMsg("VOT_ERR_48", []string{electionType}))
+	}
+
+	if u.IsAfter(todayDate, electionInfo.NominationDeadline, "2006/01/02") {
+		return nil, electionInfo, errors.New(msg.GetErrMsg("VOT_ERR_13", []string{todayDate, electionType,
+			fmt.Sprint("Nomination Deadline " + electionInfo.NominationDeadline)}))
+	}
+
+	return keyParts, electionInfo, nil
+}
+
+func (s *VotingChaincode) putPetition(stub shim.ChaincodeStubInterface, petitionKey string, petition *Petition) error {
+	petitionAsBytes, _ := json.Marshal(petition)
+
+	err := stub.PutState(petitionKey, petitionAsBytes)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_09", []string{petitionKey, err.Error()}))
+	}
+
+	return nil
+}
+
+// args[0] : election type
+// args[1] : endorsements a petition needs [0 turns nomination petitions off]
+// args[2] : nomination deadline [yyyy/mm/dd, before the election start date]
+// @notice applies to petitions opened afterwards
+func (s *VotingChaincode) setNominationRules(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setNominationRules", "3"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	electionType := args[0]
+	deadline := args[2]
+
+	threshold, err := strconv.Atoi(args[1])
+	if err != nil || threshold < 0 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{args[1], "threshold must be a number of endorsements"}))
+	}
+
+	election, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = time.Parse("2006/01/02", deadline)
+	if err != nil || u.IsAfter(todayDate, deadline, "2006/01/02") || !u.IsAfter(keyParts[1], deadline, "2006/01/02") {
+		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{deadline, fmt.Sprint("deadline must be between " + todayDate + " and the day before " + keyParts[1])}))
+	}
+
+	electionInfo.NominationThreshold = threshold
+	electionInfo.NominationDeadline = deadline
+
+	electionAsBytes, _ := json.Marshal(electionInfo)
+
+	err = stub.PutState(election, electionAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{election, err.Error()}))
+	}
+
+	return shim.Success(electionAsBytes)
+}
+
+// args[0] : election type
+// args[1] : candidate account
+// args[2] : R [signature of args[0..1] by the candidate]
+// args[3] : S
+// args[4] : X
+// args[5] : Y
+func (s *VotingChaincode) openPetition(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"openPetition", "6"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	electionType := args[0]
+	account := args[1]
+	R := args[2]
+	S := args[3]
+
+	keyParts, electionInfo, err := s.getNominatingElection(stub, electionType, todayDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	user, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:2], R, S, args[4], args[5])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	err = checkVerified(user)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	candidate, err := u.FindCompositeKey(stub, c.CANDIDATE, []string{electionType, user.SSN})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if candidate != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_09", []string{candidate}))
+	}
+
+	age, isEligibleCandidate, err := s.checkAge(stub, user, keyParts[1], keyParts[2], c.CANDIDATE_MIN_AGE)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isEligibleCandidate {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint(age + " Candidate Min Age " + strconv.Itoa(c.CANDIDATE_MIN_AGE))}))
+	}
+
+	petitionKey, existing, err := s.findPetition(stub, electionType, user.SSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if existing != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_49", []string{user.SSN, getPetitionStatus(*existing, todayDate)}))
+	}
+
+	petition := Petition{
+		ElectionType: electionType,
+		CandidateSSN: user.SSN,
+		Candidate:    account,
+		Threshold:    electionInfo.NominationThreshold,
+		Deadline:     electionInfo.NominationDeadline,
+		Status:       c.PETITION_OPEN,
+		OpenDate:     todayDate,
+		TxID:         stub.GetTxID()}
+
+	err = s.putPetition(stub, petitionKey, &petition)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	petitionAsBytes, _ := json.Marshal(petition)
+
+	return shim.Success(petitionAsBytes)
+}
+
+// args[0] : election type
+// args[1] : candidate ssn
+// args[2] : voter account
+// args[3] : R [signature of args[0..2] by the voter]
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+// @notice the candidate is registered once the petition reaches its threshold
+func (s *VotingChaincode) endorsePetition(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"endorsePetition", "7"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	electionType := args[0]
+	candidateSSN := args[1]
+	account := args[2]
+	R := args[3]
+	S := args[4]
+
+	petitionKey, petition, err := s.findPetition(stub, electionType, candidateSSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if petition == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_50", []string{candidateSSN, electionType}))
+	}
+
+	status := getPetitionStatus(*petition, todayDate)
+	if status != c.PETITION_OPEN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_49", []string{candidateSSN, status}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:3], R, S, args[5], args[6])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	voter, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = checkVerified(voter)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isRegisteredFor(voter, electionType) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Voter " + voter.SSN + " Not Registered for " + electionType)}))
+	}
+
+	if voter.SSN == candidateSSN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_12", []string{candidateSSN, "Candidate Cannot Endorse the Own Petition"}))
+	}
+
+	endorsed, err := u.FindCompositeKey(stub, c.ENDORSEMENT, []string{electionType, candidateSSN, voter.SSN})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if endorsed != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_51", []string{voter.SSN, candidateSSN}))
+	}
+
+	endorsementDate := now.Format("2006/01/02 15:04:05")
+	endorsement := Endorsement{electionType, candidateSSN, voter.SSN, R, S, endorsementDate, stub.GetTxID()}
+
+	endorsementAsBytes, _ := json.Marshal(endorsement)
+	err = u.PutCompKey(stub, c.ENDORSEMENT, []string{electionType, candidateSSN, voter.SSN}, endorsementAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	petition.Endorsements++
+
+	if petition.Endorsements >= petition.Threshold {
+		err = u.CreateCompKey(stub, c.CANDIDATE, []string{electionType, candidateSSN})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		petition.Status = c.PETITION_APPROVED
+		petition.ApprovalTxID = stub.GetTxID()
+	}
+
+	err = s.putPetition(stub, petitionKey, petition)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	petitionAsBytes, _ := json.Marshal(petition)
+
+	return shim.Success(petitionAsBytes)
+}
+
+// args[0] : election type
+// args[1] : candidate ssn
+func (s *VotingChaincode) getPetition(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getPetition", "2"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	_, petition, err := s.findPetition(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if petition == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_50", []string{args[1], args[0]}))
+	}
+
+	petition.Status = getPetitionStatus(*petition, todayDate)
+	petitionAsBytes, _ := json.Marshal(petition)
+
+	return shim.Success(petitionAsBytes)
+}
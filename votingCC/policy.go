@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+func (s *VotingChaincode) findPolicy(stub shim.ChaincodeStubInterface, function, electionType string) (string, *Policy, error) {
+	policyKey, err := stub.CreateCompositeKey(c.POLICY, []string{function, electionType})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.POLICY, function, err.Error()}))
+	}
+
+	policyAsBytes, err := stub.GetState(policyKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{policyKey, err.Error()}))
+	}
+
+	if policyAsBytes == nil {
+		return policyKey, nil, nil
+	}
+
+	policy := Policy{}
+	err = json.Unmarshal(policyAsBytes, &policy)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return policyKey, &policy, nil
+}
+
+// Returns the fields of the election record by their JSON names, only the
+// election type when the election is not registered yet
+func (s *VotingChaincode) getElectionFields(stub shim.ChaincodeStubInterface, electionType string) map[string]string {
+	fields := map[string]string{"ElectionType": electionType}
+
+	_, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return fields
+	}
+
+	var record map[string]interface{}
+	electionAsBytes, _ := json.Marshal(electionInfo)
+	json.Unmarshal(electionAsBytes, &record)
+
+	for field, value := range record {
+		fields[field] = fmt.Sprint(value)
+	}
+
+	return fields
+}
+
+func describeRule(rule AttributeRule) string {
+	if rule.ElectionField != "" {
+		return fmt.Sprint(rule.Attribute + " = election " + rule.ElectionField)
+	}
+
+	return fmt.Sprint(rule.Attribute + " in [" + strings.Join(rule.Values, ", ") + "]")
+}
+
+func checkRule(stub shim.ChaincodeStubInterface, rule AttributeRule, electionFields map[string]string) bool {
+	value := u.GetAttribute(stub, rule.Attribute)
+	if value == "" {
+		return false
+	}
+
+	if rule.ElectionField != "" {
+		return value == electionFields[rule.ElectionField]
+	}
+
+	for _, allowed := range rule.Values {
+		if value == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the policies of the function for every election and for the
+// election of the call. Admins are not bound by policies.
+func (s *VotingChaincode) checkPolicies(stub shim.ChaincodeStubInterface, function, electionType string) error {
+	if u.GetRole(stub) == c.ADMIN {
+		return nil
+	}
+
+	scopes := []string{c.ANY_ELECTION}
+	if electionType != "" {
+		scopes = append(scopes, electionType)
+	}
+
+	var electionFields map[string]string
+
+	for _, scope := range scopes {
+		_, policy, err := s.findPolicy(stub, function, scope)
+		if err != nil {
+			return err
+		}
+
+		if policy == nil {
+			continue
+		}
+
+		for _, rule := range policy.Rules {
+			// @notice the election is only read when a rule needs it
+			if rule.ElectionField != "" && electionFields == nil {
+				electionFields = s.getElectionFields(stub, electionType)
+			}
+
+			if !checkRule(stub, rule, electionFields) {
+				return errors.New(msg.GetErrMsg("ACC_ERR_04", []string{function, scope, describeRule(rule)}))
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateRule(rule AttributeRule) error {
+	if rule.Attribute == "" {
+		return errors.New("rule without attribute")
+	}
+
+	if (len(rule.Values) == 0) == (rule.ElectionField == "") {
+		return errors.New(fmt.Sprint("rule on " + rule.Attribute + " needs either Values or ElectionField"))
+	}
+
+	if rule.ElectionField != "" {
+		var fields map[string]interface{}
+		electionAsBytes, _ := json.Marshal(Election{})
+		json.Unmarshal(electionAsBytes, &fields)
+
+		if _, found := fields[rule.ElectionField]; !found {
+			return errors.New(fmt.Sprint("unknown election field " + rule.ElectionField))
+		}
+	}
+
+	return nil
+}
+
+// args[0] : function
+// args[1] : election type [primary / general / local, * for every election]
+// args[2] : rules [json array of {"Attribute", "Values"} or {"Attribute", "ElectionField"}, empty array removes the policy]
+// @notice every rule must hold
+func (s *VotingChaincode) setPolicy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setPolicy", "3"}))
+	}
+
+	function := args[0]
+	electionType := args[1]
+
+	if _, found := routes[function]; !found {
+		return shim.Error(msg.GetErrMsg("COM_ERR_11", []string{function}))
+	}
+
+	if electionType != c.ANY_ELECTION && electionType != c.PRIMARY && electionType != c.GENERAL && electionType != c.LOCAL {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_04", []string{electionType}))
+	}
+
+	var rules []AttributeRule
+	err := json.Unmarshal([]byte(args[2]), &rules)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_05", []string{err.Error()}))
+	}
+
+	for _, rule := range rules {
+		err = validateRule(rule)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("ACC_ERR_05", []string{err.Error()}))
+		}
+	}
+
+	policyKey, _, err := s.findPolicy(stub, function, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(rules) == 0 {
+		err = stub.DelState(policyKey)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{policyKey, err.Error()}))
+		}
+
+		return shim.Success(nil)
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	updateDate := now.Format("2006/01/02 15:04:05")
+	policy := Policy{function, electionType, rules, updateDate, stub.GetTxID()}
+
+	policyAsBytes, _ := json.Marshal(policy)
+
+	err = stub.PutState(policyKey, policyAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{policyKey, err.Error()}))
+	}
+
+	return shim.Success(policyAsBytes)
+}
+
+// args[0] : function
+// args[1] : election type [primary / general / local, * for every election]
+func (s *VotingChaincode) getPolicy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getPolicy", "2"}))
+	}
+
+	_, policy, err := s.findPolicy(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if policy == nil {
+		return shim.Success(nil)
+	}
+
+	policyAsBytes, _ := json.Marshal(policy)
+
+	return shim.Success(policyAsBytes)
+}
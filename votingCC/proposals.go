@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Returns the number of organizations that must approve an election, 1 until
+// an admin sets it
+func (s *VotingChaincode) getQuorum(stub shim.ChaincodeStubInterface) (int, error) {
+	quorumAsBytes, err := stub.GetState(c.QUORUM)
+	if err != nil {
+		return 0, errors.New(msg.GetErrMsg("COM_ERR_10", []string{c.QUORUM, err.Error()}))
+	}
+
+	if quorumAsBytes == nil {
+		return 1, nil
+	}
+
+	return strconv.Atoi(string(quorumAsBytes))
+}
+
+func (s *VotingChaincode) findProposal(stub shim.ChaincodeStubInterface, electionType, electionID string) (string, *ElectionProposal, error) {
+	proposalKey, err := stub.CreateCompositeKey(c.ELECTION_PROPOSAL, []string{electionType, electionID})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.ELECTION_PROPOSAL, electionID, err.Error()}))
+	}
+
+	proposalAsBytes, err := stub.GetState(proposalKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{proposalKey, err.Error()}))
+	}
+
+	if proposalAsBytes == nil {
+		return proposalKey, nil, nil
+	}
+
+	proposal := ElectionProposal{}
+	err = json.Unmarshal(proposalAsBytes, &proposal)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return proposalKey, &proposal, nil
+}
+
+// Pending proposals past their expiry date are reported as expired
+func getProposalStatus(proposal ElectionProposal, todayDate string) string {
+	if proposal.Status == c.PENDING && u.IsAfter(todayDate, proposal.ExpiryDate, "2006/01/02") {
+		return c.EXPIRED
+	}
+
+	return proposal.Status
+}
+
+// Adds the approval of the submitter organization, each organization counts once
+func addApproval(stub shim.ChaincodeStubInterface, proposal *ElectionProposal) error {
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	for _, approval := range proposal.Approvals {
+		if approval.MSPID == mspID {
+			return errors.New(msg.GetErrMsg("VOT_ERR_36", []string{mspID, proposal.Election.ID}))
+		}
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	approvalDate := now.Format("2006/01/02 15:04:05")
+	proposal.Approvals = append(proposal.Approvals, Approval{mspID, subject, approvalDate, stub.GetTxID()})
+
+	return nil
+}
+
+// Registers the election of the proposal under its ELECTION composite key
+func (s *VotingChaincode) activateElection(stub shim.ChaincodeStubInterface, proposal *ElectionProposal) error {
+	electionType := proposal.Election.ElectionType
+
+	registeredElection, err := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
+	if err != nil {
+		return err
+	}
+
+	if registeredElection != "" {
+		return errors.New(msg.GetErrMsg("VOT_ERR_06", []string{registeredElection}))
+	}
+
+	// @notice the organization that proposed the election owns it
+	proposal.Election.OwnerMSP = proposal.Approvals[0].MSPID
+	proposal.Election.EndorsingOrgs, err = s.getElectionOrgs(stub, proposal.Election.OwnerMSP)
+	if err != nil {
+		return err
+	}
+
+	electionKey, err := stub.CreateCompositeKey(c.ELECTION, []string{electionType, proposal.StartDate, proposal.EndDate, proposal.Election.ID})
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.ELECTION, electionType, err.Error()}))
+	}
+
+	electionAsBytes, _ := json.Marshal(proposal.Election)
+
+	err = stub.PutState(electionKey, electionAsBytes)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_09", []string{electionKey, err.Error()}))
+	}
+
+	err = setEndorsement(stub, electionKey, proposal.Election.EndorsingOrgs)
+	if err != nil {
+		return err
+	}
+
+	proposal.Status = c.ACTIVE
+
+	return nil
+}
+
+func (s *VotingChaincode) putProposal(stub shim.ChaincodeStubInterface, proposalKey string, proposal *ElectionProposal) error {
+	proposalAsBytes, _ := json.Marshal(proposal)
+
+	err := stub.PutState(proposalKey, proposalAsBytes)
+	if err != nil {
+		return errors.New(msg.GetErrMsg("COM_ERR_09", []string{proposalKey, err.Error()}))
+	}
+
+	return nil
+}
+
+// Proposes the election with the approval of the submitter organization. The
+// election is registered right away when that is the quorum.
+func (s *VotingChaincode) proposeElection(stub shim.ChaincodeStubInterface, election Election, startDate, endDate string) (*ElectionProposal, error) {
+	proposalKey, existing, err := s.findProposal(stub, election.ElectionType, election.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	if existing != nil && getProposalStatus(*existing, todayDate) != c.EXPIRED {
+		return nil, errors.New(msg.GetErrMsg("VOT_ERR_35", []string{election.ID, getProposalStatus(*existing, todayDate)}))
+	}
+
+	quorum, err := s.getQuorum(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// @notice the proposal expires when the election starts if that comes first
+	expiryDate := now.AddDate(0, 0, c.PROPOSAL_DAYS).Format("2006/01/02")
+	if u.IsAfter(expiryDate, startDate, "2006/01/02") {
+		expiryDate = startDate
+	}
+
+	proposal := ElectionProposal{
+		Election:     election,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		Quorum:       quorum,
+		Status:       c.PENDING,
+		ProposalDate: todayDate,
+		ExpiryDate:   expiryDate,
+		TxID:         stub.GetTxID()}
+
+	err = addApproval(stub, &proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(proposal.Approvals) >= proposal.Quorum {
+		err = s.activateElection(stub, &proposal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.putProposal(stub, proposalKey, &proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proposal, nil
+}
+
+// args[0] : election type
+// args[1] : electionID
+// @notice officials of one organization approve once, the election is
+// registered when the quorum of organizations is reached
+func (s *VotingChaincode) approveElection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"approveElection", "2"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	proposalKey, proposal, err := s.findProposal(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if proposal == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_34", []string{args[1]}))
+	}
+
+	status := getProposalStatus(*proposal, todayDate)
+	if status != c.PENDING {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_35", []string{args[1], status}))
+	}
+
+	err = checkJurisdiction(stub, getJurisdiction(proposal.Election))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = addApproval(stub, proposal)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(proposal.Approvals) >= proposal.Quorum {
+		err = s.activateElection(stub, proposal)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	err = s.putProposal(stub, proposalKey, proposal)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	proposalAsBytes, _ := json.Marshal(proposal)
+
+	return shim.Success(proposalAsBytes)
+}
+
+// args[0] : election type [optional, every election type when empty]
+func (s *VotingChaincode) getPendingElections(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getPendingElections", "0 or 1"}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	todayDate := now.Format("2006/01/02")
+
+	keys := []string{}
+	if len(args) == 1 && args[0] != "" {
+		keys = append(keys, args[0])
+	}
+
+	proposalIterator, err := stub.GetStateByPartialCompositeKey(c.ELECTION_PROPOSAL, keys)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_04", []string{err.Error()}))
+	}
+	defer proposalIterator.Close()
+
+	proposals := make([]ElectionProposal, 0)
+	for proposalIterator.HasNext() {
+		record, err := proposalIterator.Next()
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+		}
+
+		proposal := ElectionProposal{}
+		json.Unmarshal(record.Value, &proposal)
+
+		if getProposalStatus(proposal, todayDate) == c.PENDING {
+			proposals = append(proposals, proposal)
+		}
+	}
+
+	proposalsAsBytes, _ := json.Marshal(proposals)
+
+	return shim.Success(proposalsAsBytes)
+}
+
+// args[0] : number of organizations that must approve an election
+// @notice applies to elections proposed from now on
+func (s *VotingChaincode) setElectionQuorum(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"setElectionQuorum", "1"}))
+	}
+
+	quorum, err := strconv.Atoi(args[0])
+	if err != nil || quorum < 1 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_37", []string{args[0]}))
+	}
+
+	err = stub.PutState(c.QUORUM, []byte(strconv.Itoa(quorum)))
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{c.QUORUM, err.Error()}))
+	}
+
+	return shim.Success([]byte(strconv.Itoa(quorum)))
+}
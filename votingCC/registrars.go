@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+func (s *VotingChaincode) findRegistrarKey(stub shim.ChaincodeStubInterface, account string) (string, *RegistrarKey, error) {
+	registrarKey, err := stub.CreateCompositeKey(c.REGISTRAR_KEY, []string{account})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.REGISTRAR_KEY, account, err.Error()}))
+	}
+
+	registrarAsBytes, err := stub.GetState(registrarKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{registrarKey, err.Error()}))
+	}
+
+	if registrarAsBytes == nil {
+		return registrarKey, nil, nil
+	}
+
+	registrar := RegistrarKey{}
+	err = json.Unmarshal(registrarAsBytes, &registrar)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return registrarKey, &registrar, nil
+}
+
+// Checks that the account is an enrolled registrar key, used by the
+// registrar it was enrolled by, and that it signed the call
+func (s *VotingChaincode) verifyRegistrar(stub shim.ChaincodeStubInterface, account string, args []string, R, S, X, Y string) (*RegistrarKey, error) {
+	_, registrar, err := s.findRegistrarKey(stub, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if registrar == nil || registrar.Status != c.ACTIVE {
+		return nil, errors.New(msg.GetErrMsg("VOT_ERR_53", []string{account}))
+	}
+
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	if mspID != registrar.MSPID || subject != registrar.Subject {
+		return nil, errors.New(msg.GetErrMsg("ACC_ERR_07", []string{account}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args, R, S, X, Y)
+	if !isVerified {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	return registrar, nil
+}
+
+// args[0] : registrar account
+// args[1] : R [signature of args[0] by the registrar]
+// args[2] : S
+// args[3] : X
+// args[4] : Y
+// @notice the key is bound to the certificate of the submitter, attestAge,
+// verifyUser and revokeVerification only accept it from that registrar
+func (s *VotingChaincode) enrollRegistrarKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 5 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"enrollRegistrarKey", "5"}))
+	}
+
+	account := args[0]
+	R := args[1]
+	S := args[2]
+
+	_, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	registrarKey, registrar, err := s.findRegistrarKey(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if registrar != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_54", []string{account, registrar.Status}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:1], R, S, args[3], args[4])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	mspID, subject, err := u.GetIdentity(stub)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_08", []string{err.Error()}))
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	enrollmentDate := now.Format("2006/01/02 15:04:05")
+	registrar = &RegistrarKey{
+		Account:        account,
+		MSPID:          mspID,
+		Subject:        subject,
+		Status:         c.ACTIVE,
+		EnrollmentDate: enrollmentDate,
+		TxID:           stub.GetTxID()}
+
+	registrarAsBytes, _ := json.Marshal(registrar)
+
+	err = stub.PutState(registrarKey, registrarAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{registrarKey, err.Error()}))
+	}
+
+	return shim.Success(registrarAsBytes)
+}
+
+// args[0] : registrar account
+// @notice a removed key cannot be enrolled again
+func (s *VotingChaincode) removeRegistrarKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"removeRegistrarKey", "1"}))
+	}
+
+	account := args[0]
+
+	registrarKey, registrar, err := s.findRegistrarKey(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if registrar == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_53", []string{account}))
+	}
+
+	if registrar.Status != c.ACTIVE {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_54", []string{account, registrar.Status}))
+	}
+
+	registrar.Status = c.REGISTRAR_REMOVED
+	registrar.ClosingTxID = stub.GetTxID()
+
+	registrarAsBytes, _ := json.Marshal(registrar)
+
+	err = stub.PutState(registrarKey, registrarAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{registrarKey, err.Error()}))
+	}
+
+	return shim.Success(registrarAsBytes)
+}
+
+// args[0] : registrar account
+func (s *VotingChaincode) getRegistrarKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getRegistrarKey", "1"}))
+	}
+
+	_, registrar, err := s.findRegistrarKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if registrar == nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_53", []string{args[0]}))
+	}
+
+	registrarAsBytes, _ := json.Marshal(registrar)
+
+	return shim.Success(registrarAsBytes)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "./utils/access"
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Returns the public keys of the voters in the ring of the election, ordered
+// by ssn, leaving out revoked keys
+func (s *VotingChaincode) getRingKeys(stub shim.ChaincodeStubInterface, electionType string) ([]string, error) {
+	ringIterator, err := stub.GetStateByPartialCompositeKey(c.RING_MEMBER, []string{electionType})
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_04", []string{err.Error()}))
+	}
+	defer ringIterator.Close()
+
+	ring := make([]string, 0)
+	for ringIterator.HasNext() {
+		member, err := ringIterator.Next()
+		if err != nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+		}
+
+		_, keyParts, err := stub.SplitCompositeKey(member.Key)
+		if err != nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_07", []string{member.Key}))
+		}
+
+		if u.IsRevoked(stub, keyParts[2]) {
+			continue
+		}
+
+		ring = append(ring, string(member.Value))
+	}
+
+	return ring, nil
+}
+
+// Moves the ring entries of the user to the new key until the rings freeze,
+// members of a frozen ring keep their key until the votes are counted
+func (s *VotingChaincode) moveRingMember(stub shim.ChaincodeStubInterface, ssn, newAccount, newPubKey, algorithm string) error {
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	for _, electionType := range []string{c.PRIMARY, c.GENERAL, c.LOCAL} {
+		member, err := u.FindCompositeKey(stub, c.RING_MEMBER, []string{electionType, ssn})
+		if err != nil {
+			return err
+		}
+
+		if member == "" {
+			continue
+		}
+
+		_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+		if err != nil {
+			return err
+		}
+
+		if electionInfo.ElectionResult != "" {
+			continue
+		}
+
+		if !u.IsAfter(keyParts[1], todayDate, "2006/01/02") {
+			return errors.New(msg.GetErrMsg("VOT_ERR_33", []string{electionType, keyParts[1]}))
+		}
+
+		if algorithm != a.P256 {
+			return errors.New(msg.GetErrMsg("VOT_ERR_19", []string{"joinRing", algorithm, electionType}))
+		}
+
+		err = stub.DelState(member)
+		if err != nil {
+			return errors.New(msg.GetErrMsg("COM_ERR_09", []string{member, err.Error()}))
+		}
+
+		err = u.PutCompKey(stub, c.RING_MEMBER, []string{electionType, ssn, newAccount}, []byte(newPubKey))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// args[0] : election type
+// args[1] : voter account
+// args[2] : R [signature of args[0..1]]
+// args[3] : S
+// args[4] : X
+// args[5] : Y
+// @notice one key per voter, the ring is frozen once the election starts
+func (s *VotingChaincode) joinRing(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"joinRing", "6"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+	account := args[1]
+	R := args[2]
+	S := args[3]
+	X := args[4]
+	Y := args[5]
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.RING {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"joinRing", electionInfo.BallotMode, electionType}))
+	}
+
+	if !u.IsAfter(keyParts[1], todayDate, "2006/01/02") {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_33", []string{electionType, keyParts[1]}))
+	}
+
+	user, err := s.getCurrentUser(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// @notice ring signatures are made over P-256 keys
+	if user.Algorithm != "" && user.Algorithm != a.P256 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"joinRing", user.Algorithm, electionType}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, account, args[:2], R, S, X, Y)
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
+	}
+
+	electionInfoParts := strings.Split(user.Election, c.SEPARATOR)
+	if len(electionInfoParts) < 7 || electionInfoParts[0] != c.REGISTERED || electionInfoParts[1] != electionType {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Voter " + user.SSN + " Not Registered")}))
+	}
+
+	if electionInfoParts[6] != "true" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint(electionInfoParts[5] + " Voter Min Age")}))
+	}
+
+	member, err := u.FindCompositeKey(stub, c.RING_MEMBER, []string{electionType, user.SSN})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if member != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_10", []string{user.SSN}))
+	}
+
+	scheme, _ := a.GetScheme(a.P256)
+	pubKey := scheme.GetPublicKey(X, Y)
+
+	err = u.PutCompKey(stub, c.RING_MEMBER, []string{electionType, user.SSN, account}, []byte(pubKey))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte(pubKey))
+}
+
+// args[0] : election type
+func (s *VotingChaincode) getRing(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getRing", "1"}))
+	}
+
+	ring, err := s.getRingKeys(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ringAsBytes, _ := json.Marshal(ring)
+
+	return shim.Success(ringAsBytes)
+}
+
+// @notice meant to be submitted from an identity that is not linked to the voter
+// args[0] : election type
+// args[1] : ring signature [json, over args[2] with the voter key and the getRing keys, its key image replaces the ssn]
+// args[2] : candidate pub key
+func (s *VotingChaincode) castRingBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castRingBallot", "3"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+	ringSignature := args[1]
+	candidatePubKey := args[2]
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.RING {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"castRingBallot", electionInfo.BallotMode, electionType}))
+	}
+
+	isElectionPeriod := u.IsWithinRange(todayDate, keyParts[1], keyParts[2], "2006/01/02")
+	if isElectionPeriod != true {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_13", []string{todayDate, electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2])}))
+	}
+
+	_, err = s.getCandidate(stub, electionType, candidatePubKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	signature := a.RingSignature{}
+	err = json.Unmarshal([]byte(ringSignature), &signature)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	ring, err := s.getRingKeys(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = a.VerifyRing(ring, candidatePubKey, getBallotContext(electionInfo), signature)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_32", []string{err.Error()}))
+	}
+
+	receiptAsBytes, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"castRingBallot", signature.KeyImage, candidatePubKey, electionType, todayDate})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	receipt := BallotReceipt{}
+	json.Unmarshal(receiptAsBytes, &receipt)
+
+	vote := Vote{
+		Candidate:    candidatePubKey,
+		KeyImage:     signature.KeyImage,
+		ElectionDate: todayDate,
+		ElectionType: electionType,
+		LeafIndex:    receipt.LeafIndex,
+		LeafHash:     receipt.LeafHash,
+		TxID:         stub.GetTxID()}
+
+	voteJSON, _ := json.Marshal(vote)
+
+	return shim.Success(voteJSON)
+}
@@ -12,6 +12,29 @@ type User struct {
 	Gender           string `json:"Gender"`
 	Election         string `json:"Election"`
 	RegistrationDate string `json:"RegistrationDate"`
+	PreviousKey      string `json:"PreviousKey"`
+	RotatedTo        string `json:"RotatedTo"`
+	Algorithm        string `json:"Algorithm"`
+	MSPID            string `json:"MSPID,omitempty"`
+	Subject          string `json:"Subject,omitempty"`
+	Residence        string `json:"Residence,omitempty"`
+	Verification     string `json:"Verification,omitempty"`
+}
+
+// Verification records a registrar changing the verification status of a
+// user, with the hashes of the evidence checked or the revocation reason
+type Verification struct {
+	SSN              string   `json:"SSN"`
+	Status           string   `json:"Status"`
+	Evidence         []string `json:"Evidence,omitempty"`
+	Reason           string   `json:"Reason,omitempty"`
+	Registrar        string   `json:"Registrar"`
+	MSPID            string   `json:"MSPID"`
+	Subject          string   `json:"Subject"`
+	R                string   `json:"R"`
+	S                string   `json:"S"`
+	VerificationDate string   `json:"VerificationDate"`
+	TxID             string   `json:"TxID"`
 }
 
 type Candidate struct {
@@ -26,11 +49,152 @@ type Candidate struct {
 }
 
 type Election struct {
-	ID             string `json:"ID"`
+	ID             string   `json:"ID"`
+	PublicKey      string   `json:"PublicKey"`
+	ElectionType   string   `json:"ElectionType"`
+	ElectionPeriod string   `json:"ElectionPeriod"`
+	ElectionResult string   `json:"ElectionResult"`
+	BallotMode     string   `json:"BallotMode"`
+	Trustees       []string `json:"Trustees"`
+	Threshold      int      `json:"Threshold"`
+	OwnerMSP       string   `json:"OwnerMSP,omitempty"`
+	EndorsingOrgs  []string `json:"EndorsingOrgs,omitempty"`
+	Jurisdiction   string   `json:"Jurisdiction,omitempty"`
+
+	NominationThreshold int    `json:"NominationThreshold,omitempty"`
+	NominationDeadline  string `json:"NominationDeadline,omitempty"`
+}
+
+type Revocation struct {
 	PublicKey      string `json:"PublicKey"`
+	Reason         string `json:"Reason"`
+	RevocationDate string `json:"RevocationDate"`
+	TxID           string `json:"TxID"`
+}
+
+// RegistrarKey is a registrar account enrolled to sign attestations and
+// verifications, bound to the certificate of the registrar
+type RegistrarKey struct {
+	Account        string `json:"Account"`
+	MSPID          string `json:"MSPID"`
+	Subject        string `json:"Subject"`
+	Status         string `json:"Status"`
+	EnrollmentDate string `json:"EnrollmentDate"`
+	ClosingTxID    string `json:"ClosingTxID,omitempty"`
+	TxID           string `json:"TxID"`
+}
+
+type AgeAttestation struct {
+	SSN             string `json:"SSN"`
+	MinAge          int    `json:"MinAge"`
+	Date            string `json:"Date"`
+	Registrar       string `json:"Registrar"`
+	MSPID           string `json:"MSPID"`
+	Subject         string `json:"Subject"`
+	R               string `json:"R"`
+	S               string `json:"S"`
+	AttestationDate string `json:"AttestationDate"`
+	TxID            string `json:"TxID"`
+}
+
+type Approval struct {
+	MSPID        string `json:"MSPID"`
+	Subject      string `json:"Subject"`
+	ApprovalDate string `json:"ApprovalDate"`
+	TxID         string `json:"TxID"`
+}
+
+// ElectionProposal becomes an election once officials of Quorum
+// organizations have approved it, by ExpiryDate at the latest
+type ElectionProposal struct {
+	Election     Election   `json:"Election"`
+	StartDate    string     `json:"StartDate"`
+	EndDate      string     `json:"EndDate"`
+	Quorum       int        `json:"Quorum"`
+	Approvals    []Approval `json:"Approvals"`
+	Status       string     `json:"Status"`
+	ProposalDate string     `json:"ProposalDate"`
+	ExpiryDate   string     `json:"ExpiryDate"`
+	TxID         string     `json:"TxID"`
+}
+
+// AttributeRule holds when the certificate attribute is one of Values, or
+// equals the ElectionField of the election the call is about.
+type AttributeRule struct {
+	Attribute     string   `json:"Attribute"`
+	Values        []string `json:"Values,omitempty"`
+	ElectionField string   `json:"ElectionField,omitempty"`
+}
+
+type Policy struct {
+	Function     string          `json:"Function"`
+	ElectionType string          `json:"ElectionType"`
+	Rules        []AttributeRule `json:"Rules"`
+	UpdateDate   string          `json:"UpdateDate"`
+	TxID         string          `json:"TxID"`
+}
+
+// Delegation lets the proxy vote for the delegator in one election, until it
+// is used or revoked
+type Delegation struct {
 	ElectionType   string `json:"ElectionType"`
-	ElectionPeriod string `json:"ElectionPeriod"`
-	ElectionResult string `json:"ElectionResult"`
+	DelegatorSSN   string `json:"DelegatorSSN"`
+	ProxySSN       string `json:"ProxySSN"`
+	Status         string `json:"Status"`
+	R              string `json:"R"`
+	S              string `json:"S"`
+	DelegationDate string `json:"DelegationDate"`
+	ClosingTxID    string `json:"ClosingTxID,omitempty"`
+	TxID           string `json:"TxID"`
+}
+
+// Petition nominates the candidate once Threshold registered voters endorse
+// it before the deadline
+type Petition struct {
+	ElectionType string `json:"ElectionType"`
+	CandidateSSN string `json:"CandidateSSN"`
+	Candidate    string `json:"Candidate"`
+	Threshold    int    `json:"Threshold"`
+	Endorsements int    `json:"Endorsements"`
+	Deadline     string `json:"Deadline"`
+	Status       string `json:"Status"`
+	OpenDate     string `json:"OpenDate"`
+	ApprovalTxID string `json:"ApprovalTxID,omitempty"`
+	TxID         string `json:"TxID"`
+}
+
+type Endorsement struct {
+	ElectionType    string `json:"ElectionType"`
+	CandidateSSN    string `json:"CandidateSSN"`
+	VoterSSN        string `json:"VoterSSN"`
+	R               string `json:"R"`
+	S               string `json:"S"`
+	EndorsementDate string `json:"EndorsementDate"`
+	TxID            string `json:"TxID"`
+}
+
+// AuditEntry records an administrative call, with the identity that made it
+type AuditEntry struct {
+	Function string   `json:"Function"`
+	Args     []string `json:"Args"`
+	Role     string   `json:"Role"`
+	MSPID    string   `json:"MSPID"`
+	Subject  string   `json:"Subject"`
+	Date     string   `json:"Date"`
+	TxID     string   `json:"TxID"`
+}
+
+type AuditLog struct {
+	Entries             []AuditEntry `json:"Entries"`
+	FetchedRecordsCount int32        `json:"FetchedRecordsCount"`
+	Bookmark            string       `json:"Bookmark"`
+}
+
+type VoterRoll struct {
+	ElectionType        string `json:"ElectionType"`
+	Voters              []User `json:"Voters"`
+	FetchedRecordsCount int32  `json:"FetchedRecordsCount"`
+	Bookmark            string `json:"Bookmark"`
 }
 
 type NewUser struct {
@@ -38,6 +202,7 @@ type NewUser struct {
 	PublicKey        string `json:"PublicKey"`
 	PrivateKey       string `json:"PrivateKey"`
 	RegistrationDate string `json:"RegistrationDate"`
+	Algorithm        string `json:"Algorithm"`
 }
 
 type NewElection struct {
@@ -45,6 +210,9 @@ type NewElection struct {
 	ElectionID   string `json:"ElectionID"`
 	StartDate    string `json:"StartDate"`
 	EndDate      string `json:"EndDate"`
+	BallotMode   string `json:"BallotMode"`
+	Status       string `json:"Status"`
+	Jurisdiction string `json:"Jurisdiction"`
 	TxID         string `json:"TxID"`
 }
 type NewCandidate struct {
@@ -73,15 +241,35 @@ type NewVoter struct {
 
 type Vote struct {
 	VoterSSN     string `json:"VoterSSN"`
+	Proxy        string `json:"Proxy,omitempty"`
 	FirstName    string `json:"FirstName"`
 	LastName     string `json:"LastName"`
 	Age          string `json:"Age"`
 	Candidate    string `json:"Candidate"`
+	Commitment   string `json:"Commitment,omitempty"`
+	KeyImage     string `json:"KeyImage,omitempty"`
 	ElectionDate string `json:"ElectionDate"`
 	ElectionType string `json:"ElectionType"`
+	LeafIndex    int    `json:"LeafIndex"`
+	LeafHash     string `json:"LeafHash"`
+	TxID         string `json:"TxID"`
+}
+
+type BallotReceipt struct {
+	ElectionType string `json:"ElectionType"`
+	LeafIndex    int    `json:"LeafIndex"`
+	LeafHash     string `json:"LeafHash"`
 	TxID         string `json:"TxID"`
 }
 
+type TokenIssuance struct {
+	SSN            string `json:"SSN"`
+	ElectionType   string `json:"ElectionType"`
+	BlindedToken   string `json:"BlindedToken"`
+	BlindSignature string `json:"BlindSignature"`
+	TxID           string `json:"TxID"`
+}
+
 type Result struct {
 	Result  string `json:"Result"`
 	Message string `json:"Message"`
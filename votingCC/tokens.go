@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "./utils/access"
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// args[0] : ssn
+// args[1] : election type
+// args[2] : blinded token
+// args[3] : election authority blind signature
+func (s *VotingChaincode) issueBallotToken(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"issueBallotToken", "4"}))
+	}
+
+	voterSSN := args[0]
+	electionType := args[1]
+	blindedToken := args[2]
+	blindSignature := args[3]
+
+	_, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.BLIND_TOKEN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"issueBallotToken", electionInfo.BallotMode, electionType}))
+	}
+
+	_, _, _, err = s.getEligibleVoter(stub, voterSSN)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	issued, err := u.FindCompositeKey(stub, c.TOKEN_ISSUED, []string{electionType, voterSSN})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if issued != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_20", []string{voterSSN}))
+	}
+
+	authorityKey, err := a.ParseRSAPublicKey(electionInfo.PublicKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+	}
+
+	if !a.VerifyBlindSignature(authorityKey, blindedToken, blindSignature) {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Blinded Token: " + blindedToken), "Invalid Blind Signature"}))
+	}
+
+	issuance := TokenIssuance{voterSSN, electionType, blindedToken, blindSignature, stub.GetTxID()}
+	issuanceAsBytes, _ := json.Marshal(issuance)
+
+	err = u.PutCompKey(stub, c.TOKEN_ISSUED, []string{electionType, voterSSN, blindedToken}, issuanceAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(issuanceAsBytes)
+}
+
+// @notice meant to be submitted from an identity that is not linked to the voter
+// args[0] : election type
+// args[1] : ballot token
+// args[2] : unblinded authority signature
+// args[3] : candidate pub key
+func (s *VotingChaincode) castBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castBallot", "4"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+	token := args[1]
+	signature := args[2]
+	candidatePubKey := args[3]
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.BLIND_TOKEN {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"castBallot", electionInfo.BallotMode, electionType}))
+	}
+
+	isElectionPeriod := u.IsWithinRange(todayDate, keyParts[1], keyParts[2], "2006/01/02")
+	if isElectionPeriod != true {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_13", []string{todayDate, electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2])}))
+	}
+
+	authorityKey, err := a.ParseRSAPublicKey(electionInfo.PublicKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+	}
+
+	if !a.VerifyTokenSignature(authorityKey, token, signature) {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Token: " + token), "Invalid Token Signature"}))
+	}
+
+	_, err = s.getCandidate(stub, electionType, candidatePubKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ballot, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"castTokenBallot", token, candidatePubKey, electionType})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(ballot)
+}
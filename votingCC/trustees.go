@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "./utils/access"
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Returns the 1-based index of the trustee account in the election
+func getTrusteeIndex(election Election, account string) int {
+	for i, trustee := range election.Trustees {
+		if trustee == account {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Returns the published key commitments ordered by trustee index, with nil
+// entries for trustees that have not published yet.
+func (s *VotingChaincode) getKeyCommitments(stub shim.ChaincodeStubInterface, election Election) ([]*a.KeyCommitments, int, error) {
+	all := make([]*a.KeyCommitments, len(election.Trustees))
+	published := 0
+
+	for i := range election.Trustees {
+		commitmentKey, err := stub.CreateCompositeKey(c.TRUSTEE_COMMITMENTS, []string{election.ElectionType, strconv.Itoa(i + 1)})
+		if err != nil {
+			return nil, 0, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.TRUSTEE_COMMITMENTS, election.ElectionType, err.Error()}))
+		}
+
+		commitmentsAsBytes, err := stub.GetState(commitmentKey)
+		if err != nil {
+			return nil, 0, errors.New(msg.GetErrMsg("COM_ERR_10", []string{commitmentKey, err.Error()}))
+		}
+
+		if commitmentsAsBytes == nil {
+			continue
+		}
+
+		commitments := a.KeyCommitments{}
+		json.Unmarshal(commitmentsAsBytes, &commitments)
+
+		all[i] = &commitments
+		published++
+	}
+
+	return all, published, nil
+}
+
+// args[0] : election type
+// args[1] : threshold
+// args[2] : trustee accounts [json array]
+func (s *VotingChaincode) registerTrustees(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"registerTrustees", "3"}))
+	}
+
+	electionType := args[0]
+
+	election, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.HOMOMORPHIC {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"registerTrustees", electionInfo.BallotMode, electionType}))
+	}
+
+	if electionInfo.PublicKey != "" || len(electionInfo.Trustees) > 0 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_24", []string{electionType}))
+	}
+
+	var trustees []string
+	err = json.Unmarshal([]byte(args[2]), &trustees)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	threshold, err := strconv.Atoi(args[1])
+	if err != nil || threshold < 1 || threshold > len(trustees) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_25", []string{args[1], strconv.Itoa(len(trustees))}))
+	}
+
+	registered := map[string]bool{}
+	for _, trustee := range trustees {
+		err = a.ValidateAccount(trustee)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_24", []string{trustee, err.Error()}))
+		}
+
+		trusteeAsBytes, err := stub.GetState(trustee)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{trustee, err.Error()}))
+		}
+
+		if trusteeAsBytes == nil || registered[trustee] {
+			return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"trustee", trustee}))
+		}
+		registered[trustee] = true
+	}
+
+	electionInfo.Trustees = trustees
+	electionInfo.Threshold = threshold
+
+	electionAsBytes, _ := json.Marshal(electionInfo)
+
+	err = stub.PutState(election, electionAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{election, err.Error()}))
+	}
+
+	return shim.Success(electionAsBytes)
+}
+
+// @notice the election key is set once every trustee has published
+// args[0] : election type
+// args[1] : trustee account
+// args[2] : key commitments [json]
+// args[3] : R
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+func (s *VotingChaincode) submitKeyCommitments(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"submitKeyCommitments", "7"}))
+	}
+
+	electionType := args[0]
+	trustee := args[1]
+
+	election, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	index := getTrusteeIndex(electionInfo, trustee)
+	if index == 0 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_26", []string{trustee, electionType}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, trustee, args[:3], args[3], args[4], args[5], args[6])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + args[3] + " S: " + args[4]), fmt.Sprint(err)}))
+	}
+
+	commitments := a.KeyCommitments{}
+	err = json.Unmarshal([]byte(args[2]), &commitments)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	err = a.VerifyKeyCommitments(commitments, electionInfo.Threshold, index, getBallotContext(electionInfo))
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+	}
+
+	all, published, err := s.getKeyCommitments(stub, electionInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if all[index-1] != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_27", []string{trustee, "Key Commitments"}))
+	}
+
+	commitmentsAsBytes, _ := json.Marshal(commitments)
+
+	err = u.PutCompKey(stub, c.TRUSTEE_COMMITMENTS, []string{electionType, strconv.Itoa(index)}, commitmentsAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	all[index-1] = &commitments
+	published++
+
+	if published == len(electionInfo.Trustees) {
+		keyCommitments := make([]a.KeyCommitments, 0)
+		for _, trusteeCommitments := range all {
+			keyCommitments = append(keyCommitments, *trusteeCommitments)
+		}
+
+		electionInfo.PublicKey, err = a.CombinePublicKey(keyCommitments)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+		}
+	}
+
+	electionAsBytes, _ := json.Marshal(electionInfo)
+
+	err = stub.PutState(election, electionAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{election, err.Error()}))
+	}
+
+	return shim.Success(electionAsBytes)
+}
+
+// @notice the tally is decrypted as soon as threshold trustees have submitted
+// args[0] : election type
+// args[1] : trustee account
+// args[2] : partial decryption shares [json array, one per candidate in ballot order]
+// args[3] : R
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+func (s *VotingChaincode) submitPartialDecryption(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"submitPartialDecryption", "7"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	electionType := args[0]
+	trustee := args[1]
+
+	_, keyParts, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	isElectionOver := u.IsAfter(todayDate, keyParts[2], "2006/01/02")
+	if !isElectionOver {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_17", []string{electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2]), todayDate}))
+	}
+
+	index := getTrusteeIndex(electionInfo, trustee)
+	if index == 0 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_26", []string{trustee, electionType}))
+	}
+
+	isVerified, hash, err := u.VerifyUser(stub, trustee, args[:3], args[3], args[4], args[5], args[6])
+	if !isVerified {
+		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
+			" R: " + args[3] + " S: " + args[4]), fmt.Sprint(err)}))
+	}
+
+	all, published, err := s.getKeyCommitments(stub, electionInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if published != len(electionInfo.Trustees) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_22", []string{electionType}))
+	}
+
+	keyCommitments := make([]a.KeyCommitments, 0)
+	for _, trusteeCommitments := range all {
+		keyCommitments = append(keyCommitments, *trusteeCommitments)
+	}
+
+	verificationKey, err := a.VerificationKey(keyCommitments, index)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+	}
+
+	tally, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"submitPartialDecryption", electionType,
+		getBallotContext(electionInfo), strconv.Itoa(index), verificationKey, strconv.Itoa(electionInfo.Threshold), args[2]})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(tally)
+}
@@ -1,13 +1,14 @@
 package access
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"math/big"
+	"strconv"
+	"strings"
 
 	enc "github.com/btcsuite/btcutil/base58"
 )
@@ -27,25 +28,6 @@ type PubKeyCoordinate struct {
 	Y string
 }
 
-func GenerateKeys() (*Keys, error) {
-	var keys Keys
-
-	privKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	pubKey := &privKey.PublicKey
-
-	keys.PrivateKey, keys.PublicKey = encodeKeys(privKey, pubKey)
-
-	return &keys, nil
-}
-
-func encodeKeys(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) (string, string) {
-
-	pubKeyEncoded := enc.Encode(elliptic.Marshal(elliptic.P256(), publicKey.X, publicKey.Y))
-	privKeyEncoded := hex.EncodeToString(privateKey.D.Bytes())
-
-	return privKeyEncoded, pubKeyEncoded
-}
-
 func GetHash(s string) string {
 	signedBytes := make([]int8, sha256.Size)
 	unsignedBytes := make([]byte, 0, len(signedBytes))
@@ -64,28 +46,30 @@ func GetHash(s string) string {
 	return hash
 }
 
-func Sign(privateKey string, hash string) (*Signature, error) {
-	var signature Signature
+// GetSignedPayload returns the payload users sign for a call : the JSON array
+// of the function name followed by its arguments, without the signature, so
+// a signature is only valid for the call it was made for
+func GetSignedPayload(function string, args []string) string {
+	var payload bytes.Buffer
 
-	key := getPrivateKeyFromHex(privateKey)
-	r, s, err := ecdsa.Sign(rand.Reader, key, []byte(hash))
-	if err != nil {
-		return nil, err
-	}
-	signature.R = fmt.Sprint(r)
-	signature.S = fmt.Sprint(s)
+	encoder := json.NewEncoder(&payload)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(append([]string{function}, args...))
 
-	return &signature, nil
+	return strings.TrimSuffix(payload.String(), "\n")
 }
 
-func getPrivateKeyFromHex(key string) *ecdsa.PrivateKey {
-	keyBytes := getBytesFromHex(key)
-
-	privKey := new(ecdsa.PrivateKey)
-	privKey.PublicKey.Curve = elliptic.P256()
-	privKey.D = new(big.Int).SetBytes(keyBytes)
+// GetCommitment binds the choice of a commit-reveal ballot to the election
+// context and the voter, so a commitment cannot be replayed in another
+// election or by another voter
+func GetCommitment(context, voter, choice, salt string) string {
+	return GetHash(GetSignedPayload("commitment", []string{context, voter, choice, salt}))
+}
 
-	return privKey
+// GetBallotID derives the ID of a revealed ballot from the salt, which is
+// never stored next to the voter, so the ballot is not linked to the commitment
+func GetBallotID(context, salt string) string {
+	return GetHash(GetSignedPayload("ballot", []string{context, salt}))
 }
 
 func getBytesFromHex(str string) []byte {
@@ -103,12 +87,6 @@ func getBytesFromHex(str string) []byte {
 	return bytes
 }
 
-func Verify(pubKey, msg, R, S string) bool {
-
-	pub := getPubKeyFromHex(pubKey)
-	return ecdsa.Verify(&pub, []byte(msg), getBigInt(R), getBigInt(S))
-}
-
 func getBigInt(val string) *big.Int {
 	bigInt := new(big.Int)
 	bigInt, ok := bigInt.SetString(val, 10)
@@ -118,42 +96,55 @@ func getBigInt(val string) *big.Int {
 	return bigInt
 }
 
-func getPubKeyFromHex(key string) ecdsa.PublicKey {
+const (
+	ACCOUNT_VERSION       = 0x01
+	LEGACY_ACCOUNT_LENGTH = 40
+)
 
-	keyBytes := enc.Decode(key)
-	x, y := elliptic.Unmarshal(elliptic.P256(), keyBytes)
-	pubKey := ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     x,
-		Y:     y,
-	}
+// GenerateAccount returns the base58check encoding of the version byte and
+// the first 20 bytes of SHA-256 over the public key
+func GenerateAccount(pubKey string) string {
+	keyHash := sha256.Sum256(enc.Decode(pubKey))
+	return enc.CheckEncode(keyHash[:20], ACCOUNT_VERSION)
+}
 
-	// @notice: pubKey.X; pubKey.Y
+// generateLegacyAccount returns the last 40 characters of the key hash, the
+// format of accounts registered before versioned addresses
+func generateLegacyAccount(pubKey string) string {
+	keyHash := GetHash(pubKey)
+	return keyHash[len(keyHash)-40 : len(keyHash)]
+}
 
-	return pubKey
+func IsLegacyAccount(account string) bool {
+	return len(account) == LEGACY_ACCOUNT_LENGTH && len(enc.Decode(account)) > 0
 }
 
-func GetPubKeyFromXY(x, y string) string {
+func ValidateAccount(account string) error {
+	if IsLegacyAccount(account) {
+		return nil
+	}
 
-	// @notice : when using Java generated X, Y use base 16 - not 10
-	X := new(big.Int)
-	X, ok := X.SetString(x, 10)
-	if !ok {
-		return ""
+	payload, version, err := enc.CheckDecode(account)
+	if err != nil {
+		return err
 	}
 
-	Y := new(big.Int)
-	Y, ok = Y.SetString(y, 10)
-	if !ok {
-		return ""
+	if version != ACCOUNT_VERSION {
+		return errors.New("unsupported account version " + strconv.Itoa(int(version)))
 	}
 
-	pubKey := enc.Encode(elliptic.Marshal(elliptic.P256(), X, Y))
+	if len(payload) != 20 {
+		return errors.New("invalid account length")
+	}
 
-	return pubKey
+	return nil
 }
 
-func GenerateAccount(pubKey string) string {
-	keyHash := GetHash(pubKey)
-	return keyHash[len(keyHash)-40 : len(keyHash)]
+// IsAccountOf checks that the account, in either format, belongs to the key
+func IsAccountOf(account, pubKey string) bool {
+	if IsLegacyAccount(account) {
+		return account == generateLegacyAccount(pubKey)
+	}
+
+	return account == GenerateAccount(pubKey)
 }
@@ -0,0 +1,405 @@
+package access
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestHomomorphicTally(test *testing.T) {
+	privKey, pubKey, err := GenerateElectionKeys()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	context := "primary"
+	choices := []int{0, 2, 2, 1, 2}
+	expected := []int{1, 1, 3}
+
+	var tally []Ciphertext
+	for _, choice := range choices {
+		ballot, _, err := EncryptBallot(pubKey, len(expected), choice, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if err = VerifyBallot(pubKey, *ballot, len(expected), context); err != nil {
+			test.Fatal(err)
+		}
+
+		if tally, err = AddCiphertexts(tally, ballot.Ciphertexts); err != nil {
+			test.Fatal(err)
+		}
+	}
+
+	for i, ciphertext := range tally {
+		share, err := DecryptShare(privKey, ciphertext, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if !VerifyShare(pubKey, ciphertext, *share, context) {
+			test.Fatal("decryption share rejected for candidate", i)
+		}
+
+		count, err := DecryptCount(ciphertext, share.D, len(choices))
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if count != expected[i] {
+			test.Fatal("candidate", i, "expected", expected[i], "got", count)
+		}
+	}
+}
+
+func TestInvalidBallotRejected(test *testing.T) {
+	_, pubKey, _ := GenerateElectionKeys()
+
+	ballot, _, _ := EncryptBallot(pubKey, 2, 0, "primary")
+
+	if VerifyBallot(pubKey, *ballot, 2, "general") == nil {
+		test.Fatal("ballot accepted outside of its election context")
+	}
+
+	// a ballot that votes for both candidates does not sum to one
+	other, _, _ := EncryptBallot(pubKey, 2, 1, "primary")
+	ballot.Ciphertexts[1] = other.Ciphertexts[1]
+	ballot.BitProofs[1] = other.BitProofs[1]
+
+	if VerifyBallot(pubKey, *ballot, 2, "primary") == nil {
+		test.Fatal("double vote accepted")
+	}
+}
+
+func TestOpenBallot(test *testing.T) {
+	_, pubKey, _ := GenerateElectionKeys()
+
+	ballot, randomness, _ := EncryptBallot(pubKey, 3, 1, "primary")
+
+	choice, err := OpenBallot(pubKey, *ballot, randomness)
+	if err != nil || choice != 1 {
+		test.Fatal("expected candidate 1, got", choice, err)
+	}
+
+	randomness[0], randomness[1] = randomness[1], randomness[0]
+	if _, err = OpenBallot(pubKey, *ballot, randomness); err == nil {
+		test.Fatal("ballot opened with wrong randomness")
+	}
+}
+
+func TestThresholdDecryption(test *testing.T) {
+	trustees, threshold := 3, 2
+	context := "general"
+
+	coefficients := make([][]string, trustees)
+	commitments := make([]KeyCommitments, trustees)
+
+	for j := 0; j < trustees; j++ {
+		coeffs, published, err := GeneratePolynomial(threshold, j+1, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if err = VerifyKeyCommitments(*published, threshold, j+1, context); err != nil {
+			test.Fatal(err)
+		}
+
+		coefficients[j], commitments[j] = coeffs, *published
+	}
+
+	keyShares := make([]string, trustees)
+	for i := 1; i <= trustees; i++ {
+		received := make([]string, 0)
+		for j := 0; j < trustees; j++ {
+			share := EvaluateShare(coefficients[j], i)
+			if !VerifyKeyShare(commitments[j], i, share) {
+				test.Fatal("key share rejected, dealer", j+1, "trustee", i)
+			}
+			received = append(received, share)
+		}
+		keyShares[i-1] = CombineKeyShares(received)
+	}
+
+	pubKey, err := CombinePublicKey(commitments)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	ballot, _, _ := EncryptBallot(pubKey, 2, 1, context)
+	other, _, _ := EncryptBallot(pubKey, 2, 1, context)
+	tally, _ := AddCiphertexts(ballot.Ciphertexts, other.Ciphertexts)
+
+	// any two out of three trustees decrypt the tally
+	indices := []int{1, 3}
+	expected := []int{0, 2}
+
+	for c, ciphertext := range tally {
+		partials := make([]string, 0)
+
+		for _, i := range indices {
+			share, _ := DecryptShare(keyShares[i-1], ciphertext, context)
+
+			verificationKey, _ := VerificationKey(commitments, i)
+			if !VerifyShare(verificationKey, ciphertext, *share, context) {
+				test.Fatal("partial decryption rejected, trustee", i)
+			}
+			partials = append(partials, share.D)
+		}
+
+		d, err := CombinePartialDecryptions(indices, partials)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		count, err := DecryptCount(ciphertext, d, 2)
+		if err != nil || count != expected[c] {
+			test.Fatal("candidate", c, "expected", expected[c], "got", count, err)
+		}
+	}
+}
+
+func TestSignatureSchemes(test *testing.T) {
+	data := "primary"
+
+	for _, algorithm := range []string{P256, ED25519, SECP256K1} {
+		scheme, err := GetScheme(algorithm)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		keys, err := scheme.GenerateKeys()
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if scheme.NormalizePublicKey(keys.PublicKey) != keys.PublicKey {
+			test.Fatal(algorithm, "public key rejected")
+		}
+
+		signature, err := scheme.Sign(keys.PrivateKey, data)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if !scheme.Verify(keys.PublicKey, data, *signature) {
+			test.Fatal(algorithm, "signature rejected")
+		}
+
+		if scheme.Verify(keys.PublicKey, "general", *signature) {
+			test.Fatal(algorithm, "signature accepted for another message")
+		}
+
+		coordinates, err := scheme.GetCoordinates(keys.PublicKey)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		if scheme.GetPublicKey(coordinates.X, coordinates.Y) != keys.PublicKey {
+			test.Fatal(algorithm, "public key not rebuilt from its coordinates")
+		}
+	}
+
+	// accounts created before the registry have no algorithm and verify as P-256
+	scheme, _ := GetScheme("")
+	keys := generateKeys(test)
+	signature, _ := scheme.Sign(keys.PrivateKey, data)
+
+	if p256, _ := GetScheme(P256); !p256.Verify(keys.PublicKey, data, *signature) {
+		test.Fatal("signature of an account without algorithm rejected")
+	}
+}
+
+// generateKeys returns P-256 keys from the scheme registry
+func generateKeys(test *testing.T) *Keys {
+	test.Helper()
+
+	scheme, _ := GetScheme(P256)
+
+	keys, err := scheme.GenerateKeys()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	return keys
+}
+
+func TestES256(test *testing.T) {
+	payload := "primary"
+	scheme, _ := GetScheme(P256)
+
+	keys := generateKeys(test)
+	pubKey, err := ParseP256PublicKey(keys.PublicKey)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	signature, err := SignES256(keys.PrivateKey, payload)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	if !scheme.Verify(keys.PublicKey, payload, Signature{R: signature}) {
+		test.Fatal("raw ES256 signature rejected")
+	}
+
+	raw, _ := decodeBase64URL(signature)
+	der, _ := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(raw[:32]), new(big.Int).SetBytes(raw[32:])})
+	if !VerifyES256(pubKey, payload, base64.RawURLEncoding.EncodeToString(der)) {
+		test.Fatal("DER ES256 signature rejected")
+	}
+
+	if VerifyES256(pubKey, "general", signature) {
+		test.Fatal("ES256 signature accepted for another payload")
+	}
+
+	jwk := fmt.Sprintf(`{"kty":"EC","crv":"P-256","x":"%s","y":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(pubKey.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(pubKey.Y.FillBytes(make([]byte, 32))))
+	sec1 := base64.RawURLEncoding.EncodeToString(elliptic.Marshal(elliptic.P256(), pubKey.X, pubKey.Y))
+
+	for _, key := range []string{jwk, sec1} {
+		if scheme.NormalizePublicKey(key) != keys.PublicKey {
+			test.Fatal("public key not normalized :", key)
+		}
+
+		if scheme.GetPublicKey(key, "") != keys.PublicKey {
+			test.Fatal("public key not rebuilt :", key)
+		}
+	}
+}
+
+func TestAccountFormat(test *testing.T) {
+	keys := generateKeys(test)
+
+	account := GenerateAccount(keys.PublicKey)
+	if err := ValidateAccount(account); err != nil {
+		test.Fatal(err)
+	}
+
+	if !IsAccountOf(account, keys.PublicKey) {
+		test.Fatal("account does not match its key")
+	}
+
+	legacy := generateLegacyAccount(keys.PublicKey)
+	if err := ValidateAccount(legacy); err != nil {
+		test.Fatal("legacy account rejected :", err)
+	}
+
+	if !IsAccountOf(legacy, keys.PublicKey) {
+		test.Fatal("legacy account does not match its key")
+	}
+
+	other := generateKeys(test)
+	if IsAccountOf(account, other.PublicKey) || IsAccountOf(legacy, other.PublicKey) {
+		test.Fatal("account matches another key")
+	}
+
+	typo := []byte(account)
+	if typo[5] == 'a' {
+		typo[5] = 'b'
+	} else {
+		typo[5] = 'a'
+	}
+
+	if ValidateAccount(string(typo)) == nil {
+		test.Fatal("mistyped account accepted")
+	}
+}
+
+func TestSignedPayload(test *testing.T) {
+	payload := GetSignedPayload("revokeKey", []string{"account", "lost <key> & \"phone\""})
+	if payload != `["revokeKey","account","lost <key> & \"phone\""]` {
+		test.Fatal("unexpected payload :", payload)
+	}
+
+	// @notice the same arguments signed for another function or split
+	// differently give another payload
+	if GetSignedPayload("rotateKey", []string{"account", "key"}) == GetSignedPayload("revokeKey", []string{"account", "key"}) ||
+		GetSignedPayload("f", []string{"a-b", "c"}) == GetSignedPayload("f", []string{"a", "b-c"}) {
+		test.Fatal("payloads collide")
+	}
+}
+
+func TestRingSignature(test *testing.T) {
+	context := "primary"
+
+	var ring, privKeys []string
+	for i := 0; i < 4; i++ {
+		keys := generateKeys(test)
+		ring = append(ring, keys.PublicKey)
+		privKeys = append(privKeys, keys.PrivateKey)
+	}
+
+	signature, err := SignRing(privKeys[2], ring, "candidate", context)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	if err = VerifyRing(ring, "candidate", context, *signature); err != nil {
+		test.Fatal(err)
+	}
+
+	if VerifyRing(ring, "other", context, *signature) == nil {
+		test.Fatal("ring signature accepted for another message")
+	}
+
+	// a second signature with the same key links through the key image
+	second, _ := SignRing(privKeys[2], ring, "other", context)
+	if second.KeyImage != signature.KeyImage {
+		test.Fatal("key images of the same key differ")
+	}
+
+	third, _ := SignRing(privKeys[1], ring, "candidate", context)
+	if third.KeyImage == signature.KeyImage {
+		test.Fatal("key images of different keys match")
+	}
+
+	outsider := generateKeys(test)
+	if _, err = SignRing(outsider.PrivateKey, ring, "candidate", context); err == nil {
+		test.Fatal("outsider signed for the ring")
+	}
+}
+
+func TestSealedChoice(test *testing.T) {
+	privKey, pubKey, err := GenerateElectionKeys()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	context := "primary"
+	sealed, randomness, err := SealChoice(pubKey, "candidate", context)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	if err = ValidateSealedChoice(sealed); err != nil {
+		test.Fatal(err)
+	}
+
+	choice, err := OpenSealedChoice(privKey, sealed, context)
+	if err != nil || choice != "candidate" {
+		test.Fatal("authority opened", choice, err)
+	}
+
+	choice, err = OpenSealedChoiceWithRandomness(pubKey, sealed, randomness, context)
+	if err != nil || choice != "candidate" {
+		test.Fatal("voter opened", choice, err)
+	}
+
+	if _, err = OpenSealedChoice(privKey, sealed, "general"); err == nil {
+		test.Fatal("sealed ballot opened in another election")
+	}
+
+	if !MatchesElectionKey(privKey, pubKey) {
+		test.Fatal("authority key rejected")
+	}
+
+	otherKey, _, _ := GenerateElectionKeys()
+	if MatchesElectionKey(otherKey, pubKey) {
+		test.Fatal("foreign key accepted")
+	}
+}
@@ -0,0 +1,131 @@
+package access
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	enc "github.com/btcsuite/btcutil/base58"
+)
+
+// Blind RSA-FDH signatures over ballot tokens. The election authority signs a
+// blinded token without learning it; the voter unblinds the signature and
+// later spends the token from an identity that is not linked to the issuance.
+// The authority key must not sign anything but blinded tokens.
+
+const minRSABits = 2048
+
+func EncodeRSAPublicKey(pubKey *rsa.PublicKey) (string, error) {
+	keyBytes, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+
+	return enc.Encode(keyBytes), nil
+}
+
+func ParseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	parsed, err := x509.ParsePKIXPublicKey(enc.Decode(key))
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+
+	if pubKey.N.BitLen() < minRSABits {
+		return nil, errors.New("RSA key shorter than 2048 bits")
+	}
+
+	return pubKey, nil
+}
+
+// Full domain hash of the token : SHA-256 in counter mode expanded to one bit
+// less than the modulus, so signatures of tokens cannot be combined into the
+// signature of another token
+func getTokenDigest(pubKey *rsa.PublicKey, token string) *big.Int {
+	size := (pubKey.N.BitLen() + 7) / 8
+
+	digest := make([]byte, 0, size+sha256.Size)
+	for counter := uint32(0); len(digest) < size; counter++ {
+		hash := sha256.New()
+		hash.Write([]byte("blind-token"))
+		binary.Write(hash, binary.BigEndian, counter)
+		hash.Write([]byte(token))
+		digest = hash.Sum(digest)
+	}
+
+	value := new(big.Int).SetBytes(digest[:size])
+
+	return value.Rsh(value, uint(size*8-pubKey.N.BitLen()+1))
+}
+
+func decodeInt(val string) *big.Int {
+	return new(big.Int).SetBytes(enc.Decode(val))
+}
+
+func encodeInt(val *big.Int) string {
+	return enc.Encode(val.Bytes())
+}
+
+// BlindToken returns the blinded token digest and the blinding factor the
+// voter keeps to unblind the authority signature.
+func BlindToken(pubKey *rsa.PublicKey, token string) (string, string, error) {
+	e := big.NewInt(int64(pubKey.E))
+
+	for {
+		r, err := rand.Int(rand.Reader, pubKey.N)
+		if err != nil {
+			return "", "", err
+		}
+
+		if r.Sign() == 0 || new(big.Int).GCD(nil, nil, r, pubKey.N).Cmp(big.NewInt(1)) != 0 {
+			continue
+		}
+
+		blinded := new(big.Int).Exp(r, e, pubKey.N)
+		blinded.Mul(blinded, getTokenDigest(pubKey, token))
+		blinded.Mod(blinded, pubKey.N)
+
+		return encodeInt(blinded), encodeInt(r), nil
+	}
+}
+
+func SignBlindedToken(privKey *rsa.PrivateKey, blinded string) string {
+	return encodeInt(new(big.Int).Exp(decodeInt(blinded), privKey.D, privKey.N))
+}
+
+func UnblindSignature(pubKey *rsa.PublicKey, blindSignature, blindingFactor string) (string, error) {
+	rInv := new(big.Int).ModInverse(decodeInt(blindingFactor), pubKey.N)
+	if rInv == nil {
+		return "", errors.New("invalid blinding factor")
+	}
+
+	signature := new(big.Int).Mul(decodeInt(blindSignature), rInv)
+	signature.Mod(signature, pubKey.N)
+
+	return encodeInt(signature), nil
+}
+
+func verifyRSA(pubKey *rsa.PublicKey, message *big.Int, signature string) bool {
+	sig := decodeInt(signature)
+	if sig.Sign() == 0 || sig.Cmp(pubKey.N) >= 0 {
+		return false
+	}
+
+	return new(big.Int).Exp(sig, big.NewInt(int64(pubKey.E)), pubKey.N).Cmp(message) == 0
+}
+
+func VerifyBlindSignature(pubKey *rsa.PublicKey, blinded, blindSignature string) bool {
+	return verifyRSA(pubKey, new(big.Int).Mod(decodeInt(blinded), pubKey.N), blindSignature)
+}
+
+func VerifyTokenSignature(pubKey *rsa.PublicKey, token, signature string) bool {
+	return verifyRSA(pubKey, getTokenDigest(pubKey, token), signature)
+}
@@ -0,0 +1,60 @@
+package access
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+)
+
+func TestBlindToken(test *testing.T) {
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	encoded, _ := EncodeRSAPublicKey(&privKey.PublicKey)
+	pubKey, err := ParseRSAPublicKey(encoded)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	sign := func(token string) string {
+		blinded, blindingFactor, err := BlindToken(pubKey, token)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		blindSignature := SignBlindedToken(privKey, blinded)
+		if !VerifyBlindSignature(pubKey, blinded, blindSignature) {
+			test.Fatal("blind signature rejected")
+		}
+
+		signature, err := UnblindSignature(pubKey, blindSignature, blindingFactor)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		return signature
+	}
+
+	first := sign("token 1")
+	second := sign("token 2")
+
+	if !VerifyTokenSignature(pubKey, "token 1", first) || VerifyTokenSignature(pubKey, "token 2", first) {
+		test.Fatal("token signature not bound to its token")
+	}
+
+	// @notice the digest fills the modulus, products of signatures sign no token
+	if getTokenDigest(pubKey, "token 1").BitLen() < pubKey.N.BitLen()-16 {
+		test.Fatal("digest does not fill the modulus")
+	}
+
+	product := new(big.Int).Mul(decodeInt(first), decodeInt(second))
+	if VerifyTokenSignature(pubKey, "token 1token 2", encodeInt(product.Mod(product, pubKey.N))) {
+		test.Fatal("forged token signature accepted")
+	}
+
+	shortKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	encoded, _ = EncodeRSAPublicKey(&shortKey.PublicKey)
+	if _, err = ParseRSAPublicKey(encoded); err == nil {
+		test.Fatal("short RSA key accepted")
+	}
+}
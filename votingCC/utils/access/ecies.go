@@ -0,0 +1,164 @@
+package access
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	enc "github.com/btcsuite/btcutil/base58"
+)
+
+// ECIES over P-256 for sealed ballots. The choice is encrypted with
+// AES-256-GCM under SHA-256 of the x coordinate of rH, where H is the
+// election authority key. The sealed ballot is base58 of R = rG || nonce ||
+// ciphertext and the ballot context is authenticated as additional data.
+// Whoever knows r can open the ballot too, which lets a voter check how the
+// ballot was counted.
+
+const (
+	pointSize = 65
+	nonceSize = 12
+)
+
+func getSealKey(shared point) []byte {
+	key := sha256.Sum256(append([]byte("ecies~"), shared.X.FillBytes(make([]byte, 32))...))
+	return key[:]
+}
+
+func getSealCipher(shared point) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(getSealKey(shared))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+type sealedChoice struct {
+	R          point
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func decodeSealedChoice(sealed string) (*sealedChoice, error) {
+	sealedBytes := enc.Decode(sealed)
+	if len(sealedBytes) <= pointSize+nonceSize {
+		return nil, errors.New("sealed ballot is too short")
+	}
+
+	x, y := elliptic.Unmarshal(curve, sealedBytes[:pointSize])
+	if x == nil {
+		return nil, errors.New("invalid ephemeral key")
+	}
+
+	return &sealedChoice{
+		R:          point{x, y},
+		Nonce:      sealedBytes[pointSize : pointSize+nonceSize],
+		Ciphertext: sealedBytes[pointSize+nonceSize:],
+	}, nil
+}
+
+func ValidateSealedChoice(sealed string) error {
+	_, err := decodeSealedChoice(sealed)
+	return err
+}
+
+// SealChoice returns the sealed ballot and the randomness r the voter keeps
+func SealChoice(pubKey, choice, context string) (string, string, error) {
+	h, err := decodePoint(pubKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	if h.isInfinity() {
+		return "", "", errors.New("invalid election key")
+	}
+
+	r, err := randomScalar()
+	if err != nil {
+		return "", "", err
+	}
+
+	aead, err := getSealCipher(h.mul(r))
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err = rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+
+	R := baseMul(r)
+	sealed := elliptic.Marshal(curve, R.X, R.Y)
+	sealed = append(sealed, nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(choice), []byte(context))
+
+	return enc.Encode(sealed), encodeInt(r), nil
+}
+
+func openSealedChoice(shared point, sealed *sealedChoice, context string) (string, error) {
+	aead, err := getSealCipher(shared)
+	if err != nil {
+		return "", err
+	}
+
+	choice, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(context))
+	if err != nil {
+		return "", errors.New("sealed ballot does not decrypt")
+	}
+
+	return string(choice), nil
+}
+
+// OpenSealedChoice decrypts with the base58 authority private key
+func OpenSealedChoice(privKey, sealed, context string) (string, error) {
+	x, err := decodeScalar(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	choice, err := decodeSealedChoice(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return openSealedChoice(choice.R.mul(x), choice, context)
+}
+
+// OpenSealedChoiceWithRandomness decrypts with the randomness kept by the voter
+func OpenSealedChoiceWithRandomness(pubKey, sealed, randomness, context string) (string, error) {
+	h, err := decodePoint(pubKey)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := decodeScalar(randomness)
+	if err != nil {
+		return "", err
+	}
+
+	choice, err := decodeSealedChoice(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	if !baseMul(r).equal(choice.R) {
+		return "", errors.New("randomness does not match the ballot")
+	}
+
+	return openSealedChoice(h.mul(r), choice, context)
+}
+
+// MatchesElectionKey checks that the private key belongs to the election key
+func MatchesElectionKey(privKey, pubKey string) bool {
+	x, err := decodeScalar(privKey)
+	if err != nil || x.Sign() == 0 {
+		return false
+	}
+
+	return encodePoint(baseMul(x)) == pubKey
+}
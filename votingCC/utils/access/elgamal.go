@@ -0,0 +1,492 @@
+package access
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strconv"
+
+	enc "github.com/btcsuite/btcutil/base58"
+)
+
+// Exponential ElGamal over P-256. A vote m is encrypted under the election key
+// H = xG as (A, B) = (rG, rH + mG), so ciphertexts add up to an encryption of
+// the vote count and only the aggregate ever needs to be decrypted.
+
+type Ciphertext struct {
+	A string `json:"A"`
+	B string `json:"B"`
+}
+
+// Chaum-Pedersen proof that log_G1(P1) == log_G2(P2)
+type Proof struct {
+	C string `json:"C"`
+	Z string `json:"Z"`
+}
+
+// Disjunctive Chaum-Pedersen proof that a ciphertext encrypts 0 or 1
+type BitProof struct {
+	C0 string `json:"C0"`
+	C1 string `json:"C1"`
+	Z0 string `json:"Z0"`
+	Z1 string `json:"Z1"`
+}
+
+type EncryptedBallot struct {
+	Ciphertexts []Ciphertext `json:"Ciphertexts"`
+	BitProofs   []BitProof   `json:"BitProofs"`
+	SumProof    Proof        `json:"SumProof"`
+}
+
+type DecryptionShare struct {
+	D     string `json:"D"`
+	Proof Proof  `json:"Proof"`
+}
+
+type point struct {
+	X, Y *big.Int
+}
+
+var curve = elliptic.P256()
+
+func basePoint() point {
+	return point{curve.Params().Gx, curve.Params().Gy}
+}
+
+func infinity() point {
+	return point{new(big.Int), new(big.Int)}
+}
+
+func (p point) isInfinity() bool {
+	return p.X.Sign() == 0 && p.Y.Sign() == 0
+}
+
+func (p point) add(q point) point {
+	x, y := curve.Add(p.X, p.Y, q.X, q.Y)
+	return point{x, y}
+}
+
+func (p point) neg() point {
+	if p.isInfinity() {
+		return p
+	}
+	return point{p.X, new(big.Int).Sub(curve.Params().P, p.Y)}
+}
+
+func (p point) sub(q point) point {
+	return p.add(q.neg())
+}
+
+func (p point) mul(k *big.Int) point {
+	x, y := curve.ScalarMult(p.X, p.Y, new(big.Int).Mod(k, curve.Params().N).Bytes())
+	return point{x, y}
+}
+
+func (p point) equal(q point) bool {
+	return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0
+}
+
+func baseMul(k *big.Int) point {
+	x, y := curve.ScalarBaseMult(new(big.Int).Mod(k, curve.Params().N).Bytes())
+	return point{x, y}
+}
+
+// @notice the point at infinity is encoded as an empty string
+func encodePoint(p point) string {
+	if p.isInfinity() {
+		return ""
+	}
+	return enc.Encode(elliptic.Marshal(curve, p.X, p.Y))
+}
+
+func decodePoint(s string) (point, error) {
+	if s == "" {
+		return infinity(), nil
+	}
+
+	x, y := elliptic.Unmarshal(curve, enc.Decode(s))
+	if x == nil {
+		return point{}, errors.New("invalid P-256 point : " + s)
+	}
+
+	return point{x, y}, nil
+}
+
+func decodeScalar(s string) (*big.Int, error) {
+	k := decodeInt(s)
+	if k.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid P-256 scalar : " + s)
+	}
+	return k, nil
+}
+
+func randomScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, curve.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+func getChallenge(context string, points ...point) *big.Int {
+	hash := sha256.New()
+	hash.Write([]byte(context))
+
+	for _, p := range points {
+		hash.Write([]byte{0x00})
+		hash.Write([]byte(encodePoint(p)))
+	}
+
+	return new(big.Int).Mod(new(big.Int).SetBytes(hash.Sum(nil)), curve.Params().N)
+}
+
+func modN(k *big.Int) *big.Int {
+	return k.Mod(k, curve.Params().N)
+}
+
+func ValidatePoint(key string) error {
+	p, err := decodePoint(key)
+	if err != nil {
+		return err
+	}
+	if p.isInfinity() {
+		return errors.New("point at infinity")
+	}
+	return nil
+}
+
+// GenerateElectionKeys returns the base58 encoded private scalar x and public point H = xG
+func GenerateElectionKeys() (string, string, error) {
+	x, err := randomScalar()
+	if err != nil {
+		return "", "", err
+	}
+
+	return encodeInt(x), encodePoint(baseMul(x)), nil
+}
+
+func proveDLEQ(context string, g1, p1, g2, p2 point, secret *big.Int) (Proof, error) {
+	w, err := randomScalar()
+	if err != nil {
+		return Proof{}, err
+	}
+
+	c := getChallenge(context, g1, p1, g2, p2, g1.mul(w), g2.mul(w))
+	z := modN(new(big.Int).Add(w, new(big.Int).Mul(c, secret)))
+
+	return Proof{encodeInt(c), encodeInt(z)}, nil
+}
+
+func verifyDLEQ(context string, g1, p1, g2, p2 point, proof Proof) bool {
+	c, err := decodeScalar(proof.C)
+	if err != nil {
+		return false
+	}
+
+	z, err := decodeScalar(proof.Z)
+	if err != nil {
+		return false
+	}
+
+	a := g1.mul(z).sub(p1.mul(c))
+	b := g2.mul(z).sub(p2.mul(c))
+
+	return getChallenge(context, g1, p1, g2, p2, a, b).Cmp(c) == 0
+}
+
+func proveBit(context string, h point, ct [2]point, m int, r *big.Int) (BitProof, error) {
+	g := basePoint()
+	c := make([]*big.Int, 2)
+	z := make([]*big.Int, 2)
+	a := make([]point, 2)
+	b := make([]point, 2)
+
+	// simulate the branch the ballot does not take
+	k := 1 - m
+	var err error
+	if c[k], err = randomScalar(); err != nil {
+		return BitProof{}, err
+	}
+	if z[k], err = randomScalar(); err != nil {
+		return BitProof{}, err
+	}
+	a[k] = g.mul(z[k]).sub(ct[0].mul(c[k]))
+	b[k] = h.mul(z[k]).sub(ct[1].sub(baseMul(big.NewInt(int64(k)))).mul(c[k]))
+
+	w, err := randomScalar()
+	if err != nil {
+		return BitProof{}, err
+	}
+	a[m] = g.mul(w)
+	b[m] = h.mul(w)
+
+	challenge := getChallenge(context, h, ct[0], ct[1], a[0], b[0], a[1], b[1])
+	c[m] = modN(new(big.Int).Sub(challenge, c[k]))
+	z[m] = modN(new(big.Int).Add(w, new(big.Int).Mul(c[m], r)))
+
+	return BitProof{encodeInt(c[0]), encodeInt(c[1]), encodeInt(z[0]), encodeInt(z[1])}, nil
+}
+
+func verifyBit(context string, h point, ct [2]point, proof BitProof) bool {
+	g := basePoint()
+	a := make([]point, 2)
+	b := make([]point, 2)
+	sum := new(big.Int)
+
+	for j, encoded := range [][2]string{{proof.C0, proof.Z0}, {proof.C1, proof.Z1}} {
+		c, err := decodeScalar(encoded[0])
+		if err != nil {
+			return false
+		}
+
+		z, err := decodeScalar(encoded[1])
+		if err != nil {
+			return false
+		}
+
+		a[j] = g.mul(z).sub(ct[0].mul(c))
+		b[j] = h.mul(z).sub(ct[1].sub(baseMul(big.NewInt(int64(j)))).mul(c))
+		sum.Add(sum, c)
+	}
+
+	return getChallenge(context, h, ct[0], ct[1], a[0], b[0], a[1], b[1]).Cmp(modN(sum)) == 0
+}
+
+func decodeCiphertext(ciphertext Ciphertext) ([2]point, error) {
+	a, err := decodePoint(ciphertext.A)
+	if err != nil {
+		return [2]point{}, err
+	}
+
+	b, err := decodePoint(ciphertext.B)
+	if err != nil {
+		return [2]point{}, err
+	}
+
+	return [2]point{a, b}, nil
+}
+
+// EncryptBallot encrypts a vote for candidate `choice` out of `candidates`
+// under election key `pubKey`. Context binds the proofs to the election.
+// Returns the ballot and the per-candidate randomness kept by the voter.
+func EncryptBallot(pubKey string, candidates, choice int, context string) (*EncryptedBallot, []string, error) {
+	if choice < 0 || choice >= candidates {
+		return nil, nil, errors.New("choice out of range")
+	}
+
+	h, err := decodePoint(pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ballot EncryptedBallot
+	randomness := make([]string, candidates)
+	sumR := new(big.Int)
+	sumA, sumB := infinity(), infinity()
+
+	for i := 0; i < candidates; i++ {
+		m := 0
+		if i == choice {
+			m = 1
+		}
+
+		r, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ct := [2]point{baseMul(r), h.mul(r).add(baseMul(big.NewInt(int64(m))))}
+
+		proof, err := proveBit(context, h, ct, m, r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ballot.Ciphertexts = append(ballot.Ciphertexts, Ciphertext{encodePoint(ct[0]), encodePoint(ct[1])})
+		ballot.BitProofs = append(ballot.BitProofs, proof)
+		randomness[i] = encodeInt(r)
+
+		sumR = modN(sumR.Add(sumR, r))
+		sumA, sumB = sumA.add(ct[0]), sumB.add(ct[1])
+	}
+
+	ballot.SumProof, err = proveDLEQ(context, basePoint(), sumA, h, sumB.sub(basePoint()), sumR)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &ballot, randomness, nil
+}
+
+// VerifyBallot checks that every component encrypts 0 or 1 and that the
+// components add up to exactly one vote.
+func VerifyBallot(pubKey string, ballot EncryptedBallot, candidates int, context string) error {
+	if len(ballot.Ciphertexts) != candidates || len(ballot.BitProofs) != candidates {
+		return errors.New("ballot does not match the candidate list")
+	}
+
+	h, err := decodePoint(pubKey)
+	if err != nil {
+		return err
+	}
+
+	sumA, sumB := infinity(), infinity()
+
+	for i, ciphertext := range ballot.Ciphertexts {
+		ct, err := decodeCiphertext(ciphertext)
+		if err != nil {
+			return err
+		}
+
+		if !verifyBit(context, h, ct, ballot.BitProofs[i]) {
+			return errors.New("invalid 0/1 proof for candidate " + strconv.Itoa(i))
+		}
+
+		sumA, sumB = sumA.add(ct[0]), sumB.add(ct[1])
+	}
+
+	if !verifyDLEQ(context, basePoint(), sumA, h, sumB.sub(basePoint()), ballot.SumProof) {
+		return errors.New("invalid sum proof")
+	}
+
+	return nil
+}
+
+// AddCiphertexts adds two vectors of ciphertexts component-wise. An empty
+// tally is treated as a vector of encryptions of zero.
+func AddCiphertexts(tally, ballot []Ciphertext) ([]Ciphertext, error) {
+	if len(tally) == 0 {
+		tally = make([]Ciphertext, len(ballot))
+	}
+
+	if len(tally) != len(ballot) {
+		return nil, errors.New("ciphertext vectors differ in length")
+	}
+
+	sum := make([]Ciphertext, len(ballot))
+	for i := range ballot {
+		ct1, err := decodeCiphertext(tally[i])
+		if err != nil {
+			return nil, err
+		}
+
+		ct2, err := decodeCiphertext(ballot[i])
+		if err != nil {
+			return nil, err
+		}
+
+		sum[i] = Ciphertext{encodePoint(ct1[0].add(ct2[0])), encodePoint(ct1[1].add(ct2[1]))}
+	}
+
+	return sum, nil
+}
+
+// DecryptShare computes D = xA with a proof that the same x is behind the
+// verification key xG. It is run off-chain by the key holder.
+func DecryptShare(privKey string, ciphertext Ciphertext, context string) (*DecryptionShare, error) {
+	x := decodeInt(privKey)
+
+	ct, err := decodeCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	d := ct[0].mul(x)
+
+	proof, err := proveDLEQ(context, basePoint(), baseMul(x), ct[0], d, x)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecryptionShare{encodePoint(d), proof}, nil
+}
+
+func VerifyShare(verificationKey string, ciphertext Ciphertext, share DecryptionShare, context string) bool {
+	h, err := decodePoint(verificationKey)
+	if err != nil {
+		return false
+	}
+
+	ct, err := decodeCiphertext(ciphertext)
+	if err != nil {
+		return false
+	}
+
+	d, err := decodePoint(share.D)
+	if err != nil {
+		return false
+	}
+
+	return verifyDLEQ(context, basePoint(), h, ct[0], d, share.Proof)
+}
+
+// DecryptCount recovers the vote count m from B - D = mG by trying every
+// value up to maxCount.
+func DecryptCount(ciphertext Ciphertext, d string, maxCount int) (int, error) {
+	ct, err := decodeCiphertext(ciphertext)
+	if err != nil {
+		return 0, err
+	}
+
+	dPoint, err := decodePoint(d)
+	if err != nil {
+		return 0, err
+	}
+
+	target := ct[1].sub(dPoint)
+	current := infinity()
+
+	for m := 0; m <= maxCount; m++ {
+		if current.equal(target) {
+			return m, nil
+		}
+		current = current.add(basePoint())
+	}
+
+	return 0, errors.New("vote count exceeds the number of ballots")
+}
+
+// OpenBallot checks the ballot against the randomness kept by the voter and
+// returns the index of the candidate it encrypts a vote for.
+func OpenBallot(pubKey string, ballot EncryptedBallot, randomness []string) (int, error) {
+	if len(randomness) != len(ballot.Ciphertexts) {
+		return 0, errors.New("randomness does not match the ballot")
+	}
+
+	h, err := decodePoint(pubKey)
+	if err != nil {
+		return 0, err
+	}
+
+	choice := -1
+	for i, ciphertext := range ballot.Ciphertexts {
+		ct, err := decodeCiphertext(ciphertext)
+		if err != nil {
+			return 0, err
+		}
+
+		r := decodeInt(randomness[i])
+		if !baseMul(r).equal(ct[0]) {
+			return 0, errors.New("randomness does not match candidate " + strconv.Itoa(i))
+		}
+
+		m := ct[1].sub(h.mul(r))
+		if m.equal(basePoint()) && choice == -1 {
+			choice = i
+		} else if !m.isInfinity() {
+			return 0, errors.New("ballot does not encrypt a single vote")
+		}
+	}
+
+	if choice == -1 {
+		return 0, errors.New("ballot does not encrypt a single vote")
+	}
+
+	return choice, nil
+}
@@ -0,0 +1,187 @@
+package access
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+
+	enc "github.com/btcsuite/btcutil/base58"
+)
+
+// Standard ES256 ( JWS, WebCrypto ) signatures for P-256 accounts. The
+// signature is ECDSA over SHA-256 of the UTF-8 payload, passed in R as
+// base64url raw R||S or DER with an empty S. Public keys are accepted as
+// JWK, or SEC1 in base58 or base64url.
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type p256Scheme struct {
+	ecdsaScheme
+}
+
+func decodeBase64URL(val string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(val, "="))
+}
+
+func unmarshalP256(keyBytes []byte) (*big.Int, *big.Int) {
+	curve := elliptic.P256()
+
+	if len(keyBytes) == 33 {
+		return elliptic.UnmarshalCompressed(curve, keyBytes)
+	}
+
+	return elliptic.Unmarshal(curve, keyBytes)
+}
+
+// ParseP256PublicKey reads a JWK or a SEC1 key in base58 or base64url
+func ParseP256PublicKey(key string) (*ecdsa.PublicKey, error) {
+	key = strings.TrimSpace(key)
+	curve := elliptic.P256()
+
+	if strings.HasPrefix(key, "{") {
+		var jwk JWK
+		if err := json.Unmarshal([]byte(key), &jwk); err != nil {
+			return nil, err
+		}
+
+		if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+			return nil, errors.New("expected an EC P-256 JWK")
+		}
+
+		return getP256Coordinates(jwk.X, jwk.Y)
+	}
+
+	x, y := unmarshalP256(enc.Decode(key))
+	if x == nil {
+		keyBytes, err := decodeBase64URL(key)
+		if err != nil {
+			return nil, errors.New("invalid P-256 public key")
+		}
+
+		x, y = unmarshalP256(keyBytes)
+		if x == nil {
+			return nil, errors.New("invalid P-256 public key")
+		}
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// getP256Coordinates reads base64url JWK coordinates
+func getP256Coordinates(x, y string) (*ecdsa.PublicKey, error) {
+	xBytes, errX := decodeBase64URL(x)
+	yBytes, errY := decodeBase64URL(y)
+	if errX != nil || errY != nil || len(xBytes) != 32 || len(yBytes) != 32 {
+		return nil, errors.New("invalid JWK coordinates")
+	}
+
+	X := new(big.Int).SetBytes(xBytes)
+	Y := new(big.Int).SetBytes(yBytes)
+	if !elliptic.P256().IsOnCurve(X, Y) {
+		return nil, errors.New("point is not on P-256")
+	}
+
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: X, Y: Y}, nil
+}
+
+func encodeP256PublicKey(pubKey *ecdsa.PublicKey) string {
+	return enc.Encode(elliptic.Marshal(elliptic.P256(), pubKey.X, pubKey.Y))
+}
+
+// SignES256 returns the base64url raw R||S signature of the payload
+func SignES256(privateKey, payload string) (string, error) {
+	key := new(ecdsa.PrivateKey)
+	key.PublicKey.Curve = elliptic.P256()
+	key.D = new(big.Int).SetBytes(getBytesFromHex(privateKey))
+	key.PublicKey.X, key.PublicKey.Y = elliptic.P256().ScalarBaseMult(key.D.Bytes())
+
+	digest := sha256.Sum256([]byte(payload))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyES256 checks a base64url raw R||S or DER signature of the payload
+func VerifyES256(pubKey *ecdsa.PublicKey, payload, signature string) bool {
+	sigBytes, err := decodeBase64URL(signature)
+	if err != nil {
+		return false
+	}
+
+	var sig struct {
+		R, S *big.Int
+	}
+
+	if len(sigBytes) == 64 {
+		sig.R = new(big.Int).SetBytes(sigBytes[:32])
+		sig.S = new(big.Int).SetBytes(sigBytes[32:])
+	} else if rest, err := asn1.Unmarshal(sigBytes, &sig); err != nil || len(rest) != 0 {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(payload))
+
+	return ecdsa.Verify(pubKey, digest[:], sig.R, sig.S)
+}
+
+// Verify accepts the legacy decimal R, S signature, or an ES256 signature in
+// R when S is empty.
+func (scheme p256Scheme) Verify(pubKey, data string, signature Signature) bool {
+	if signature.S != "" {
+		return scheme.ecdsaScheme.Verify(pubKey, data, signature)
+	}
+
+	key, err := ParseP256PublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	return VerifyES256(key, data, signature.R)
+}
+
+func (p256Scheme) NormalizePublicKey(pubKey string) string {
+	key, err := ParseP256PublicKey(pubKey)
+	if err != nil {
+		return ""
+	}
+
+	return encodeP256PublicKey(key)
+}
+
+// GetPublicKey accepts decimal X, Y, base64url JWK coordinates, or a JWK or
+// SEC1 key in X with an empty Y.
+func (scheme p256Scheme) GetPublicKey(x, y string) string {
+	if y == "" {
+		return scheme.NormalizePublicKey(x)
+	}
+
+	if pubKey := scheme.ecdsaScheme.GetPublicKey(x, y); pubKey != "" {
+		return pubKey
+	}
+
+	key, err := getP256Coordinates(x, y)
+	if err != nil {
+		return ""
+	}
+
+	return encodeP256PublicKey(key)
+}
@@ -0,0 +1,167 @@
+package access
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Linkable spontaneous anonymous group ( LSAG ) signatures over P-256. The
+// signer proves knowledge of the private key of one ring member without
+// revealing which, and publishes the key image I = x*Hp(P). The key image is
+// the same for every signature made with a key, so a second ballot from the
+// same voter is detected without learning who the voter is.
+
+type RingSignature struct {
+	C0       string   `json:"C0"`
+	S        []string `json:"S"`
+	KeyImage string   `json:"KeyImage"`
+}
+
+// hashToPoint maps a public key to a curve point with unknown discrete log,
+// by try-and-increment on the compressed x coordinate.
+func hashToPoint(p point) point {
+	for counter := 0; ; counter++ {
+		digest := sha256.Sum256([]byte("ring~" + encodePoint(p) + "~" + strconv.Itoa(counter)))
+		x, y := elliptic.UnmarshalCompressed(curve, append([]byte{0x02}, digest[:]...))
+		if x != nil {
+			return point{x, y}
+		}
+	}
+}
+
+func decodeRing(ring []string) ([]point, error) {
+	if len(ring) < 2 {
+		return nil, errors.New("ring needs at least 2 members")
+	}
+
+	points := make([]point, len(ring))
+	for i, member := range ring {
+		p, err := decodePoint(member)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.isInfinity() {
+			return nil, errors.New("ring member " + strconv.Itoa(i) + " is the point at infinity")
+		}
+		points[i] = p
+	}
+
+	return points, nil
+}
+
+// @notice the ring is part of the challenge so a signature cannot be moved to another ring
+func getRingContext(context, message string, ring []string) string {
+	return context + "~" + message + "~" + strings.Join(ring, ",")
+}
+
+// SignRing signs the message with the hex private key, whose public key must be in the ring
+func SignRing(privateKey string, ring []string, message, context string) (*RingSignature, error) {
+	points, err := decodeRing(ring)
+	if err != nil {
+		return nil, err
+	}
+
+	x := new(big.Int).SetBytes(getBytesFromHex(privateKey))
+	if x.Sign() == 0 || x.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+
+	pubKey := baseMul(x)
+	signer := -1
+	for i, p := range points {
+		if p.equal(pubKey) {
+			signer = i
+			break
+		}
+	}
+
+	if signer < 0 {
+		return nil, errors.New("signer is not a ring member")
+	}
+
+	n := len(points)
+	ringContext := getRingContext(context, message, ring)
+	keyImage := hashToPoint(pubKey).mul(x)
+
+	alpha, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	c := make([]*big.Int, n)
+	s := make([]*big.Int, n)
+
+	c[(signer+1)%n] = getChallenge(ringContext, baseMul(alpha), hashToPoint(pubKey).mul(alpha))
+
+	for k := 1; k < n; k++ {
+		i := (signer + k) % n
+
+		s[i], err = randomScalar()
+		if err != nil {
+			return nil, err
+		}
+
+		L := baseMul(s[i]).add(points[i].mul(c[i]))
+		R := hashToPoint(points[i]).mul(s[i]).add(keyImage.mul(c[i]))
+		c[(i+1)%n] = getChallenge(ringContext, L, R)
+	}
+
+	s[signer] = modN(new(big.Int).Sub(alpha, new(big.Int).Mul(c[signer], x)))
+
+	signature := RingSignature{C0: encodeInt(c[0]), S: make([]string, n), KeyImage: encodePoint(keyImage)}
+	for i := range s {
+		signature.S[i] = encodeInt(s[i])
+	}
+
+	return &signature, nil
+}
+
+func VerifyRing(ring []string, message, context string, signature RingSignature) error {
+	points, err := decodeRing(ring)
+	if err != nil {
+		return err
+	}
+
+	if len(signature.S) != len(points) {
+		return errors.New("expected " + strconv.Itoa(len(points)) + " responses")
+	}
+
+	keyImage, err := decodePoint(signature.KeyImage)
+	if err != nil {
+		return err
+	}
+
+	if keyImage.isInfinity() {
+		return errors.New("key image is the point at infinity")
+	}
+
+	c0, err := decodeScalar(signature.C0)
+	if err != nil {
+		return err
+	}
+
+	ringContext := getRingContext(context, message, ring)
+
+	c := c0
+	for i, p := range points {
+		s, err := decodeScalar(signature.S[i])
+		if err != nil {
+			return err
+		}
+
+		L := baseMul(s).add(p.mul(c))
+		R := hashToPoint(p).mul(s).add(keyImage.mul(c))
+		c = getChallenge(ringContext, L, R)
+	}
+
+	if c.Cmp(c0) != 0 {
+		return errors.New("ring signature does not close")
+	}
+
+	return nil
+}
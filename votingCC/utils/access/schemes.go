@@ -0,0 +1,191 @@
+package access
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	enc "github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	P256      = "p256"
+	ED25519   = "ed25519"
+	SECP256K1 = "secp256k1"
+)
+
+// Scheme signs and verifies data with one algorithm. Public keys are base58
+// encoded, private keys hex encoded and signatures split into R and S.
+type Scheme interface {
+	GenerateKeys() (*Keys, error)
+	Sign(privateKey, data string) (*Signature, error)
+	Verify(pubKey, data string, signature Signature) bool
+	// NormalizePublicKey returns the base58 encoding the account is derived
+	// from, or an empty string when the key is invalid
+	NormalizePublicKey(pubKey string) string
+	// GetPublicKey rebuilds the encoded public key from the X, Y arguments of a transaction
+	GetPublicKey(x, y string) string
+	// GetCoordinates returns the X, Y arguments GetPublicKey rebuilds the key from
+	GetCoordinates(pubKey string) (*PubKeyCoordinate, error)
+}
+
+var schemes = map[string]Scheme{
+	P256:      p256Scheme{ecdsaScheme{elliptic.P256()}},
+	SECP256K1: ecdsaScheme{btcec.S256()},
+	ED25519:   ed25519Scheme{},
+}
+
+// GetScheme returns the signature scheme of the algorithm, P-256 when empty
+func GetScheme(algorithm string) (Scheme, error) {
+	if algorithm == "" {
+		algorithm = P256
+	}
+
+	scheme, ok := schemes[algorithm]
+	if !ok {
+		return nil, errors.New("unsupported signature algorithm " + algorithm)
+	}
+
+	return scheme, nil
+}
+
+// ECDSA over any short Weierstrass curve. Signatures are made over the
+// base58 digest string of the data and R, S are decimal, like the original
+// P-256 code.
+type ecdsaScheme struct {
+	curve elliptic.Curve
+}
+
+func (scheme ecdsaScheme) GenerateKeys() (*Keys, error) {
+	privKey, err := ecdsa.GenerateKey(scheme.curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keys{
+		PrivateKey: hex.EncodeToString(privKey.D.Bytes()),
+		PublicKey:  enc.Encode(elliptic.Marshal(scheme.curve, privKey.X, privKey.Y)),
+	}, nil
+}
+
+func (scheme ecdsaScheme) Sign(privateKey, data string) (*Signature, error) {
+	key := new(ecdsa.PrivateKey)
+	key.PublicKey.Curve = scheme.curve
+	key.D = new(big.Int).SetBytes(getBytesFromHex(privateKey))
+	key.PublicKey.X, key.PublicKey.Y = scheme.curve.ScalarBaseMult(key.D.Bytes())
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, []byte(GetHash(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signature{fmt.Sprint(r), fmt.Sprint(s)}, nil
+}
+
+func (scheme ecdsaScheme) Verify(pubKey, data string, signature Signature) bool {
+	x, y := elliptic.Unmarshal(scheme.curve, enc.Decode(pubKey))
+	r, s := getBigInt(signature.R), getBigInt(signature.S)
+	if x == nil || r == nil || s == nil {
+		return false
+	}
+
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: scheme.curve, X: x, Y: y}, []byte(GetHash(data)), r, s)
+}
+
+func (scheme ecdsaScheme) NormalizePublicKey(pubKey string) string {
+	x, _ := elliptic.Unmarshal(scheme.curve, enc.Decode(pubKey))
+	if x == nil {
+		return ""
+	}
+
+	return pubKey
+}
+
+func (scheme ecdsaScheme) GetPublicKey(x, y string) string {
+	X, okX := new(big.Int).SetString(x, 10)
+	Y, okY := new(big.Int).SetString(y, 10)
+	if !okX || !okY || !scheme.curve.IsOnCurve(X, Y) {
+		return ""
+	}
+
+	return enc.Encode(elliptic.Marshal(scheme.curve, X, Y))
+}
+
+func (scheme ecdsaScheme) GetCoordinates(pubKey string) (*PubKeyCoordinate, error) {
+	x, y := elliptic.Unmarshal(scheme.curve, enc.Decode(pubKey))
+	if x == nil {
+		return nil, errors.New("invalid public key")
+	}
+
+	return &PubKeyCoordinate{x.String(), y.String()}, nil
+}
+
+// Ed25519 keys have no coordinates: the base58 public key travels in X and Y
+// is left empty. R and S are the base58 halves of the 64-byte signature.
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) GenerateKeys() (*Keys, error) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keys{
+		PrivateKey: hex.EncodeToString(privKey.Seed()),
+		PublicKey:  enc.Encode(pubKey),
+	}, nil
+}
+
+func (ed25519Scheme) Sign(privateKey, data string) (*Signature, error) {
+	seed := getBytesFromHex(privateKey)
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), []byte(GetHash(data)))
+
+	return &Signature{enc.Encode(sig[:32]), enc.Encode(sig[32:])}, nil
+}
+
+func (scheme ed25519Scheme) Verify(pubKey, data string, signature Signature) bool {
+	if scheme.NormalizePublicKey(pubKey) == "" {
+		return false
+	}
+
+	r, s := enc.Decode(signature.R), enc.Decode(signature.S)
+	if len(r) != 32 || len(s) != 32 {
+		return false
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(enc.Decode(pubKey)), []byte(GetHash(data)), append(r, s...))
+}
+
+func (ed25519Scheme) NormalizePublicKey(pubKey string) string {
+	if len(enc.Decode(pubKey)) != ed25519.PublicKeySize {
+		return ""
+	}
+
+	return pubKey
+}
+
+func (scheme ed25519Scheme) GetPublicKey(x, y string) string {
+	if y != "" {
+		return ""
+	}
+
+	return scheme.NormalizePublicKey(x)
+}
+
+func (scheme ed25519Scheme) GetCoordinates(pubKey string) (*PubKeyCoordinate, error) {
+	if scheme.NormalizePublicKey(pubKey) == "" {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	return &PubKeyCoordinate{pubKey, ""}, nil
+}
@@ -0,0 +1,215 @@
+package access
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// Joint Feldman key generation among N trustees. Every trustee j picks a
+// random polynomial f_j of degree T-1, publishes commitments a_jk*G to its
+// coefficients and privately hands f_j(i) to trustee i. The election key is
+// the sum of the constant term commitments, and trustee i holds the share
+// x_i = sum_j f_j(i) of a private key nobody ever reconstructs. Any T
+// trustees decrypt the tally together through Lagrange interpolation.
+
+type KeyCommitments struct {
+	Commitments []string `json:"Commitments"`
+	Proof       Proof    `json:"Proof"`
+}
+
+func getTrusteeContext(context string, index int) string {
+	return context + "~trustee~" + strconv.Itoa(index)
+}
+
+// GeneratePolynomial returns the secret coefficients a trustee keeps and the
+// commitments it publishes, together with a proof of knowledge of a_j0.
+func GeneratePolynomial(threshold, index int, context string) ([]string, *KeyCommitments, error) {
+	if threshold < 1 {
+		return nil, nil, errors.New("threshold must be positive")
+	}
+
+	coefficients := make([]string, threshold)
+	commitments := KeyCommitments{Commitments: make([]string, threshold)}
+
+	var secret *big.Int
+	for k := 0; k < threshold; k++ {
+		a, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if k == 0 {
+			secret = a
+		}
+
+		coefficients[k] = encodeInt(a)
+		commitments.Commitments[k] = encodePoint(baseMul(a))
+	}
+
+	g := basePoint()
+	c0 := baseMul(secret)
+
+	proof, err := proveDLEQ(getTrusteeContext(context, index), g, c0, g, c0, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	commitments.Proof = proof
+
+	return coefficients, &commitments, nil
+}
+
+// EvaluateShare computes f_j(index), sent privately to trustee `index`
+func EvaluateShare(coefficients []string, index int) string {
+	x := big.NewInt(int64(index))
+	share := new(big.Int)
+
+	for k := len(coefficients) - 1; k >= 0; k-- {
+		share.Mul(share, x)
+		share.Add(share, decodeInt(coefficients[k]))
+		modN(share)
+	}
+
+	return encodeInt(share)
+}
+
+func evaluateCommitments(commitments []point, index int) point {
+	x := big.NewInt(int64(index))
+	power := big.NewInt(1)
+	result := infinity()
+
+	for _, commitment := range commitments {
+		result = result.add(commitment.mul(power))
+		power = modN(new(big.Int).Mul(power, x))
+	}
+
+	return result
+}
+
+func decodeCommitments(commitments KeyCommitments) ([]point, error) {
+	points := make([]point, len(commitments.Commitments))
+
+	for k, commitment := range commitments.Commitments {
+		p, err := decodePoint(commitment)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.isInfinity() {
+			return nil, errors.New("commitment " + strconv.Itoa(k) + " is the point at infinity")
+		}
+		points[k] = p
+	}
+
+	return points, nil
+}
+
+// VerifyKeyShare lets trustee `index` check the share received from a dealer
+func VerifyKeyShare(commitments KeyCommitments, index int, share string) bool {
+	points, err := decodeCommitments(commitments)
+	if err != nil {
+		return false
+	}
+
+	return baseMul(decodeInt(share)).equal(evaluateCommitments(points, index))
+}
+
+func VerifyKeyCommitments(commitments KeyCommitments, threshold, index int, context string) error {
+	if len(commitments.Commitments) != threshold {
+		return errors.New("expected " + strconv.Itoa(threshold) + " commitments")
+	}
+
+	points, err := decodeCommitments(commitments)
+	if err != nil {
+		return err
+	}
+
+	g := basePoint()
+	if !verifyDLEQ(getTrusteeContext(context, index), g, points[0], g, points[0], commitments.Proof) {
+		return errors.New("invalid proof of knowledge")
+	}
+
+	return nil
+}
+
+// CombineKeyShares adds up the shares a trustee received from every dealer
+func CombineKeyShares(shares []string) string {
+	sum := new(big.Int)
+	for _, share := range shares {
+		sum.Add(sum, decodeInt(share))
+	}
+
+	return encodeInt(modN(sum))
+}
+
+func CombinePublicKey(all []KeyCommitments) (string, error) {
+	sum := infinity()
+
+	for _, commitments := range all {
+		points, err := decodeCommitments(commitments)
+		if err != nil {
+			return "", err
+		}
+		sum = sum.add(points[0])
+	}
+
+	return encodePoint(sum), nil
+}
+
+// VerificationKey returns x_i*G for trustee `index`, used to check its
+// partial decryptions.
+func VerificationKey(all []KeyCommitments, index int) (string, error) {
+	sum := infinity()
+
+	for _, commitments := range all {
+		points, err := decodeCommitments(commitments)
+		if err != nil {
+			return "", err
+		}
+		sum = sum.add(evaluateCommitments(points, index))
+	}
+
+	return encodePoint(sum), nil
+}
+
+func getLagrangeCoefficient(indices []int, i int) *big.Int {
+	numerator := big.NewInt(1)
+	denominator := big.NewInt(1)
+
+	for _, j := range indices {
+		if j == i {
+			continue
+		}
+		numerator = modN(numerator.Mul(numerator, big.NewInt(int64(j))))
+		denominator = modN(denominator.Mul(denominator, modN(big.NewInt(int64(j-i)))))
+	}
+
+	return modN(numerator.Mul(numerator, new(big.Int).ModInverse(denominator, curve.Params().N)))
+}
+
+// CombinePartialDecryptions interpolates D = xA from the partial
+// decryptions D_i = x_i*A of the trustees in `indices`.
+func CombinePartialDecryptions(indices []int, partials []string) (string, error) {
+	if len(indices) != len(partials) {
+		return "", errors.New("indices do not match partial decryptions")
+	}
+
+	seen := map[int]bool{}
+	for _, i := range indices {
+		if i < 1 || seen[i] {
+			return "", errors.New("invalid trustee index " + strconv.Itoa(i))
+		}
+		seen[i] = true
+	}
+
+	sum := infinity()
+	for k, partial := range partials {
+		d, err := decodePoint(partial)
+		if err != nil {
+			return "", err
+		}
+		sum = sum.add(d.mul(getLagrangeCoefficient(indices, indices[k])))
+	}
+
+	return encodePoint(sum), nil
+}
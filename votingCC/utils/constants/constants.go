@@ -8,9 +8,57 @@ const (
 
 const (
 	SSNKEY        = "ssn~publicKey"
+	REVOKED_KEY   = "publicKey~revocationDate"
+	AGE_ATTESTED  = "ssn~minAge~date"
+	REGISTRAR_KEY = "registrarAccount"
 	ELECTION      = "electionType~startDate~endDate~electionID"
 	CANDIDATE     = "electionType~ssn"
 	VOTING_CHOICE = "electionType~candidate~date~ssn"
+	VOTING_COMMIT = "electionType~ssn~commitment"
+	BALLOT        = "electionType~ballotID~candidate"
+	TOKEN_ISSUED  = "electionType~ssn~blindedToken"
+	SPENT_TOKEN   = "electionType~tokenHash"
+
+	ENCRYPTED_BALLOT = "electionType~ssn~ciphertexts"
+	ENCRYPTED_TALLY  = "electionType~ciphertexts"
+
+	TRUSTEE_COMMITMENTS = "electionType~trusteeIndex~commitments"
+	PARTIAL_DECRYPTION  = "electionType~trusteeIndex~shares"
+
+	BALLOT_LOG  = "electionType~ballotLog"
+	BALLOT_LEAF = "electionType~leafIndex"
+	BALLOT_NODE = "electionType~level~nodeIndex"
+
+	RING_MEMBER = "electionType~ssn~account"
+	KEY_IMAGE   = "electionType~keyImage"
+
+	SEALED_BALLOT = "electionType~ballotID"
+	SEALED_TALLY  = "electionType~sealedTally"
+
+	POLICY = "function~electionType"
+
+	BOUND_IDENTITY = "mspID~subject"
+
+	ELECTION_PROPOSAL = "electionType~electionID"
+
+	AUDIT_ENTRY = "date~time~txID"
+
+	VERIFICATION = "ssn~txID"
+
+	DELEGATION = "electionType~delegatorSSN"
+	PROXY      = "electionType~proxySSN~delegatorSSN"
+
+	PETITION    = "electionType~candidateSSN"
+	ENDORSEMENT = "electionType~candidateSSN~voterSSN"
+)
+
+const (
+	OPEN          = "open"
+	COMMIT_REVEAL = "commit-reveal"
+	BLIND_TOKEN   = "blind-token"
+	HOMOMORPHIC   = "homomorphic"
+	RING          = "ring-signature"
+	ECIES         = "ecies"
 )
 
 const (
@@ -18,19 +66,85 @@ const (
 	USERKEY  = "userkey"
 )
 
+const (
+	UNVERIFIED           = "unverified"
+	VERIFIED             = "verified"
+	VERIFICATION_REVOKED = "revoked"
+)
+
+const (
+	DELEGATION_REVOKED = "revoked"
+	DELEGATION_USED    = "used"
+)
+
+const REGISTRAR_REMOVED = "removed"
+
+const (
+	PETITION_OPEN     = "open"
+	PETITION_APPROVED = "approved"
+)
+
+const (
+	ROTATED   = "rotated"
+	RECOVERED = "recovered"
+)
+
 const (
 	REGISTERED = "registered"
 	VOTED      = "voted"
 )
 
+const (
+	PENDING = "pending"
+	ACTIVE  = "active"
+	EXPIRED = "expired"
+)
+
+// QUORUM is the state key of the number of organizations that must approve
+// an election, which is proposed for PROPOSAL_DAYS at most
+const (
+	QUORUM        = "electionQuorum"
+	PROPOSAL_DAYS = 7
+)
+
+// Commit-reveal votes are revealed within REVEAL_DAYS after the election
+// ends and counted once the reveal period is over
+const REVEAL_DAYS = 7
+
+// AUDITOR_ORGS is the state key of the MSP IDs that endorse every change to
+// elections and their results
+const AUDITOR_ORGS = "auditorOrgs"
+
 const (
 	CANDIDATE_MIN_AGE = 25
 	VOTER_MIN_AGE     = 18
 )
 
+const AUTHORITY_KEY = "authorityKey"
+
+// ANY_ELECTION is the election type of policies that apply to every election
+const ANY_ELECTION = "*"
+
+const (
+	ROLE_ATTRIBUTE = "voting.role"
+	ADMIN          = "admin"
+	REGISTRAR      = "registrar"
+	OFFICIAL       = "official"
+	AUDITOR        = "auditor"
+	VOTER          = "voter"
+)
+
+// Officials are bound to the jurisdiction attribute of their certificate,
+// elections without a jurisdiction are national
+const (
+	JURISDICTION_ATTRIBUTE = "voting.jurisdiction"
+	NATIONAL               = "national"
+)
+
 const (
-	CCNAME    = "elect_cc"
-	CHANNELID = "mychannel"
+	CCNAME        = "elect_cc"
+	VOTING_CCNAME = "voting_cc"
+	CHANNELID     = "mychannel"
 )
 
 const (
@@ -0,0 +1,80 @@
+package elect_cc
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "../constants"
+	u "../keyUtils"
+	msg "../msg"
+)
+
+// args[0] : election type
+// args[1] : bookmark [empty for the first page]
+// args[2] : page size
+func (s *ElectChaincode) getBallots(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getBallots", "3"}))
+	}
+
+	pageSize, err := u.GetPageSize(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	page, err := u.GetCompositeKeyPage(stub, c.BALLOT_LEAF, []string{args[0]}, pageSize, args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ballotPage := BallotPage{make([]AuditedBallot, 0), page.FetchedRecordsCount, page.Bookmark}
+	for _, record := range page.Records {
+		leaf := BallotLeaf{}
+		json.Unmarshal([]byte(record.Value), &leaf)
+
+		ballotAsBytes, err := stub.GetState(leaf.BallotKey)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{leaf.BallotKey, err.Error()}))
+		}
+
+		ballotPage.Ballots = append(ballotPage.Ballots, AuditedBallot{leaf.LeafIndex, leaf.LeafHash, leaf.BallotKey, string(ballotAsBytes)})
+	}
+
+	ballotPageAsBytes, _ := json.Marshal(ballotPage)
+
+	return shim.Success(ballotPageAsBytes)
+}
+
+// args[0] : object type
+// args[1] : attributes [json array the keys start with, empty for every key]
+// args[2] : bookmark [empty for the first page]
+// args[3] : page size
+func (s *ElectChaincode) listCompositeKeys(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"listCompositeKeys", "4"}))
+	}
+
+	pageSize, err := u.GetPageSize(args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	attributes := []string{}
+	if args[1] != "" {
+		err = json.Unmarshal([]byte(args[1]), &attributes)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+		}
+	}
+
+	page, err := u.GetCompositeKeyPage(stub, args[0], attributes, pageSize, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pageAsBytes, _ := json.Marshal(page)
+
+	return shim.Success(pageAsBytes)
+}
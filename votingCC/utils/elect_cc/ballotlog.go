@@ -0,0 +1,449 @@
+package elect_cc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "../access"
+	c "../constants"
+	u "../keyUtils"
+	"../merkle"
+	msg "../msg"
+)
+
+func getLeafIndex(index int) string {
+	return fmt.Sprintf("%010d", index)
+}
+
+func (s *ElectChaincode) getBallotLog(stub shim.ChaincodeStubInterface, electionType string) (string, BallotLog, error) {
+	ballotLog := BallotLog{ElectionType: electionType}
+
+	logKey, err := stub.CreateCompositeKey(c.BALLOT_LOG, []string{electionType})
+	if err != nil {
+		return "", ballotLog, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.BALLOT_LOG, electionType, err.Error()}))
+	}
+
+	logAsBytes, err := stub.GetState(logKey)
+	if err != nil {
+		return "", ballotLog, errors.New(msg.GetErrMsg("COM_ERR_10", []string{logKey, err.Error()}))
+	}
+
+	if logAsBytes != nil {
+		json.Unmarshal(logAsBytes, &ballotLog)
+	}
+
+	return logKey, ballotLog, nil
+}
+
+// Appends the hash of the ballot stored under ballotKey to the ballot log of
+// the election and returns the receipt handed back to the voter.
+func (s *ElectChaincode) appendBallot(stub shim.ChaincodeStubInterface, electionType, ballotKey string, ballot []byte) (BallotReceipt, error) {
+	logKey, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return BallotReceipt{}, err
+	}
+
+	if ballotLog.Closed {
+		return BallotReceipt{}, errors.New(msg.GetErrMsg("ELECT_ERR_11", []string{electionType}))
+	}
+
+	leaf := BallotLeaf{ballotLog.Size, hex.EncodeToString(merkle.LeafHash(ballot)), ballotKey}
+	leafAsBytes, _ := json.Marshal(leaf)
+
+	err = u.PutCompKey(stub, c.BALLOT_LEAF, []string{electionType, getLeafIndex(leaf.LeafIndex)}, leafAsBytes)
+	if err != nil {
+		return BallotReceipt{}, err
+	}
+
+	ballotLog.Size++
+	logAsBytes, _ := json.Marshal(ballotLog)
+
+	err = stub.PutState(logKey, logAsBytes)
+	if err != nil {
+		return BallotReceipt{}, errors.New(msg.GetErrMsg("COM_ERR_09", []string{logKey, err.Error()}))
+	}
+
+	return BallotReceipt{electionType, leaf.LeafIndex, leaf.LeafHash, stub.GetTxID()}, nil
+}
+
+func (s *ElectChaincode) getBallotLeaves(stub shim.ChaincodeStubInterface, electionType string) ([]BallotLeaf, [][]byte, error) {
+	leafKeys, err := u.GetAllCompositeKeys(stub, c.BALLOT_LEAF, []string{electionType})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leaves := make([]BallotLeaf, 0)
+	hashes := make([][]byte, 0)
+
+	for _, leafKey := range leafKeys {
+		leafAsBytes, err := stub.GetState(leafKey)
+		if err != nil {
+			return nil, nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{leafKey, err.Error()}))
+		}
+
+		leaf := BallotLeaf{}
+		json.Unmarshal(leafAsBytes, &leaf)
+
+		hash, err := hex.DecodeString(leaf.LeafHash)
+		if err != nil {
+			return nil, nil, errors.New(msg.GetErrMsg("COM_ERR_20", []string{leaf.LeafHash, err.Error()}))
+		}
+
+		leaves = append(leaves, leaf)
+		hashes = append(hashes, hash)
+	}
+
+	return leaves, hashes, nil
+}
+
+func (s *ElectChaincode) getBallotLeaf(stub shim.ChaincodeStubInterface, electionType string, index int) (*BallotLeaf, error) {
+	leafKey, err := stub.CreateCompositeKey(c.BALLOT_LEAF, []string{electionType, getLeafIndex(index)})
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.BALLOT_LEAF, electionType, err.Error()}))
+	}
+
+	leafAsBytes, err := stub.GetState(leafKey)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{leafKey, err.Error()}))
+	}
+
+	if leafAsBytes == nil {
+		return nil, nil
+	}
+
+	leaf := BallotLeaf{}
+	json.Unmarshal(leafAsBytes, &leaf)
+
+	return &leaf, nil
+}
+
+// Reads the audit path of the leaf from the inner nodes stored when the log
+// was closed, log2(Size) reads instead of every leaf of the log
+func (s *ElectChaincode) getAuditPath(stub shim.ChaincodeStubInterface, electionType string, ballotLog BallotLog, index int) ([][]byte, error) {
+	// @notice logs closed before the inner nodes were stored are rebuilt from their leaves
+	if ballotLog.Height == 0 && ballotLog.Size > 1 {
+		_, hashes, err := s.getBallotLeaves(stub, electionType)
+		if err != nil {
+			return nil, err
+		}
+
+		return merkle.AuditPath(hashes, index), nil
+	}
+
+	auditPath := make([][]byte, 0)
+	for _, node := range merkle.AuditPathNodes(index, ballotLog.Size) {
+		if node.Level == 0 {
+			leaf, err := s.getBallotLeaf(stub, electionType, node.Index)
+			if err != nil {
+				return nil, err
+			}
+
+			if leaf == nil {
+				return nil, errors.New(msg.GetErrMsg("ELECT_ERR_13", []string{strconv.Itoa(node.Index), electionType}))
+			}
+
+			hash, err := hex.DecodeString(leaf.LeafHash)
+			if err != nil {
+				return nil, errors.New(msg.GetErrMsg("COM_ERR_20", []string{leaf.LeafHash, err.Error()}))
+			}
+
+			auditPath = append(auditPath, hash)
+			continue
+		}
+
+		nodeKey, err := stub.CreateCompositeKey(c.BALLOT_NODE, []string{electionType, strconv.Itoa(node.Level), getLeafIndex(node.Index)})
+		if err != nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.BALLOT_NODE, electionType, err.Error()}))
+		}
+
+		hash, err := stub.GetState(nodeKey)
+		if err != nil || hash == nil {
+			return nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{nodeKey, fmt.Sprint(err)}))
+		}
+
+		auditPath = append(auditPath, hash)
+	}
+
+	return auditPath, nil
+}
+
+// args[0] : electionType
+func (s *ElectChaincode) closeBallotLog(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"closeBallotLog", "1"}))
+	}
+
+	electionType := args[0]
+
+	logKey, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if ballotLog.Closed {
+		logAsBytes, _ := json.Marshal(ballotLog)
+		return shim.Success(logAsBytes)
+	}
+
+	_, hashes, err := s.getBallotLeaves(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	ballotLog.Root = hex.EncodeToString(merkle.Root(hashes))
+
+	// @notice the inner nodes are kept so proofs are read instead of rebuilt
+	levels := merkle.Levels(hashes)
+	for level, nodes := range levels {
+		for i, node := range nodes {
+			err = u.PutCompKey(stub, c.BALLOT_NODE, []string{electionType, strconv.Itoa(level + 1), getLeafIndex(i)}, node)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+
+	ballotLog.Height = len(levels)
+	ballotLog.Closed = true
+	ballotLog.TxID = stub.GetTxID()
+
+	logAsBytes, _ := json.Marshal(ballotLog)
+
+	err = stub.PutState(logKey, logAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{logKey, err.Error()}))
+	}
+
+	return shim.Success(logAsBytes)
+}
+
+// args[0] : electionType
+// args[1] : leaf index
+func (s *ElectChaincode) getInclusionProof(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getInclusionProof", "2"}))
+	}
+
+	electionType := args[0]
+
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_15", []string{args[1], err.Error()}))
+	}
+
+	_, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !ballotLog.Closed {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_12", []string{electionType}))
+	}
+
+	if index < 0 || index >= ballotLog.Size {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_13", []string{args[1], electionType}))
+	}
+
+	leaf, err := s.getBallotLeaf(stub, electionType, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if leaf == nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_13", []string{args[1], electionType}))
+	}
+
+	path, err := s.getAuditPath(stub, electionType, ballotLog, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	auditPath := make([]string, 0)
+	for _, hash := range path {
+		auditPath = append(auditPath, hex.EncodeToString(hash))
+	}
+
+	proof := InclusionProof{electionType, index, leaf.LeafHash, ballotLog.Size, ballotLog.Root, auditPath}
+	proofAsBytes, _ := json.Marshal(proof)
+
+	return shim.Success(proofAsBytes)
+}
+
+// Works out what a logged ballot was counted as. Secret ballots can only be
+// opened with the salt or randomness that only the voter holds.
+func (s *ElectChaincode) getCountedChoice(stub shim.ChaincodeStubInterface, electionType, ballotKey string, ballot []byte, pubKey string, opening BallotOpening) (string, string, error) {
+	objType, _, err := stub.SplitCompositeKey(ballotKey)
+	if err != nil {
+		return "", "", errors.New(msg.GetErrMsg("COM_ERR_07", []string{ballotKey}))
+	}
+
+	switch objType {
+	case c.VOTING_CHOICE:
+		choice := VotingChoice{}
+		json.Unmarshal(ballot, &choice)
+		return choice.Candidate, "", nil
+
+	case c.BALLOT:
+		tokenBallot := Ballot{}
+		json.Unmarshal(ballot, &tokenBallot)
+		return tokenBallot.Candidate, "", nil
+
+	case c.VOTING_COMMIT:
+		commitment := VotingCommitment{}
+		json.Unmarshal(ballot, &commitment)
+
+		if a.GetCommitment(commitment.Context, commitment.VoterSSN, opening.Candidate, opening.Salt) != commitment.Commitment {
+			return "", "Commitment Not Opened", nil
+		}
+
+		revealed, err := u.FindCompositeKey(stub, c.BALLOT, []string{electionType, a.GetBallotID(commitment.Context, opening.Salt)})
+		if err != nil {
+			return "", "", err
+		}
+
+		if revealed == "" {
+			return "", "Vote Not Revealed", nil
+		}
+
+		return opening.Candidate, "", nil
+
+	case c.ENCRYPTED_BALLOT:
+		encryptedBallot := a.EncryptedBallot{}
+		json.Unmarshal(ballot, &encryptedBallot)
+
+		choice, err := a.OpenBallot(pubKey, encryptedBallot, opening.Randomness)
+		if err != nil {
+			return "", fmt.Sprint("Ballot Not Opened : " + err.Error()), nil
+		}
+
+		_, tally, err := s.getEncryptedTally(stub, electionType)
+		if err != nil {
+			return "", "", err
+		}
+
+		if tally.Counts == nil {
+			return "", "Tally Not Decrypted", nil
+		}
+
+		return tally.Candidates[choice], "", nil
+
+	case c.SEALED_BALLOT:
+		sealedBallot := SealedBallot{}
+		json.Unmarshal(ballot, &sealedBallot)
+
+		_, tally, err := s.getSealedTally(stub, electionType)
+		if err != nil {
+			return "", "", err
+		}
+
+		if tally == nil {
+			return "", "Tally Not Decrypted", nil
+		}
+
+		if len(opening.Randomness) != 1 {
+			return "", "Ballot Not Opened : expected the sealing randomness", nil
+		}
+
+		choice, err := a.OpenSealedChoiceWithRandomness(pubKey, sealedBallot.SealedChoice, opening.Randomness[0], sealedBallot.Context)
+		if err != nil {
+			return "", fmt.Sprint("Ballot Not Opened : " + err.Error()), nil
+		}
+
+		return choice, "", nil
+	}
+
+	return "", fmt.Sprint("Unknown Ballot Type " + objType), nil
+}
+
+// @notice read only, meant to be evaluated on a peer rather than submitted
+// so the opening never reaches the ledger
+// args[0] : electionType
+// args[1] : leaf index
+// args[2] : leaf hash
+// args[3] : election public key
+// args[4] : ballot opening [json]
+func (s *ElectChaincode) verifyBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 5 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"verifyBallot", "5"}))
+	}
+
+	electionType := args[0]
+	leafHash := args[2]
+
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_15", []string{args[1], err.Error()}))
+	}
+
+	opening := BallotOpening{}
+	if args[4] != "" {
+		err = json.Unmarshal([]byte(args[4]), &opening)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+		}
+	}
+
+	verification := VoteVerification{ElectionType: electionType, LeafIndex: index, LeafHash: leafHash}
+
+	_, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	leaf, err := s.getBallotLeaf(stub, electionType, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if leaf == nil || leaf.LeafHash != leafHash {
+		verification.Message = msg.GetErrMsg("ELECT_ERR_13", []string{args[1], electionType})
+		return s.verificationResponse(verification)
+	}
+
+	ballot, err := stub.GetState(leaf.BallotKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{leaf.BallotKey, err.Error()}))
+	}
+
+	verification.Exists = ballot != nil && hex.EncodeToString(merkle.LeafHash(ballot)) == leafHash
+	if !verification.Exists {
+		verification.Message = "Ballot Does Not Match Its Receipt"
+		return s.verificationResponse(verification)
+	}
+
+	if !ballotLog.Closed {
+		verification.Message = msg.GetErrMsg("ELECT_ERR_12", []string{electionType})
+		return s.verificationResponse(verification)
+	}
+
+	path, err := s.getAuditPath(stub, electionType, ballotLog, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	hash, _ := hex.DecodeString(leafHash)
+	root, _ := hex.DecodeString(ballotLog.Root)
+	verification.Root = ballotLog.Root
+	verification.Included = merkle.VerifyInclusion(hash, index, ballotLog.Size, path, root)
+
+	verification.CountedAs, verification.Message, err = s.getCountedChoice(stub, electionType, leaf.BallotKey, ballot, args[3], opening)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	verification.Counted = verification.Included && verification.CountedAs != ""
+
+	return s.verificationResponse(verification)
+}
+
+func (s *ElectChaincode) verificationResponse(verification VoteVerification) pb.Response {
+	verificationAsBytes, _ := json.Marshal(verification)
+
+	return shim.Success(verificationAsBytes)
+}
@@ -1,12 +1,15 @@
 package elect_cc
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protos/utils"
 
+	a "../access"
 	c "../constants"
 	u "../keyUtils"
 	msg "../msg"
@@ -19,15 +22,86 @@ func (s *ElectChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	return shim.Success(nil)
 }
 
+// Returns the chaincode the client proposal was sent to. It stays voting_cc
+// when voting_cc calls elect_cc with InvokeChaincode.
+func getProposalChaincode(stub shim.ChaincodeStubInterface) (string, error) {
+	signedProposal, err := stub.GetSignedProposal()
+	if err != nil {
+		return "", err
+	}
+
+	if signedProposal == nil {
+		return "", errors.New("no signed proposal")
+	}
+
+	proposal, err := utils.GetProposal(signedProposal.ProposalBytes)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := utils.GetHeader(proposal.Header)
+	if err != nil {
+		return "", err
+	}
+
+	extension, err := utils.GetChaincodeHeaderExtension(header)
+	if err != nil {
+		return "", err
+	}
+
+	return extension.GetChaincodeId().GetName(), nil
+}
+
 func (s *ElectChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 
 	function, args := stub.GetFunctionAndParameters()
 
+	// @notice ballots are only written through voting_cc, which checks the voter first
+	proposalChaincode, err := getProposalChaincode(stub)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_03", []string{err.Error()}))
+	}
+
+	if proposalChaincode != c.VOTING_CCNAME {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_02", []string{function, c.VOTING_CCNAME, proposalChaincode}))
+	}
+
 	if function == "giveVote" {
 		return s.giveVote(stub, args)
 
+	} else if function == "commitVote" {
+		return s.commitVote(stub, args)
+	} else if function == "revealVote" {
+		return s.revealVote(stub, args)
+	} else if function == "castRingBallot" {
+		return s.castRingBallot(stub, args)
+	} else if function == "castTokenBallot" {
+		return s.castTokenBallot(stub, args)
+	} else if function == "castEncryptedBallot" {
+		return s.castEncryptedBallot(stub, args)
+	} else if function == "castSealedBallot" {
+		return s.castSealedBallot(stub, args)
+	} else if function == "decryptAndTally" {
+		return s.decryptAndTally(stub, args)
+	} else if function == "decryptTally" {
+		return s.decryptTally(stub, args)
+	} else if function == "submitPartialDecryption" {
+		return s.submitPartialDecryption(stub, args)
+
+	} else if function == "closeBallotLog" {
+		return s.closeBallotLog(stub, args)
+	} else if function == "getInclusionProof" {
+		return s.getInclusionProof(u.ReadOnly(stub, function), args)
+	} else if function == "verifyBallot" {
+		return s.verifyBallot(u.ReadOnly(stub, function), args)
+
 	} else if function == "getVotingResults" {
 		return s.getVotingResults(stub, args)
+
+	} else if function == "getBallots" {
+		return s.getBallots(u.ReadOnly(stub, function), args)
+	} else if function == "listCompositeKeys" {
+		return s.listCompositeKeys(u.ReadOnly(stub, function), args)
 	}
 
 	return shim.Error(msg.GetErrMsg("COM_ERR_11", []string{function}))
@@ -37,61 +111,361 @@ func (s *ElectChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 // args[1] : candidatePublic Key
 // args[2] : electionType
 // args[3] : today Date
+// args[4] : proxy ssn [optional, when the proxy votes for the voter]
 func (s *ElectChaincode) giveVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 && len(args) != 5 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"giveVote", "4 or 5"}))
+	}
+
+	choiceKey, err := stub.CreateCompositeKey(c.VOTING_CHOICE, []string{args[2], args[1], args[3], args[0]})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.VOTING_CHOICE, args[1], err.Error()}))
+	}
+
+	result, _ := u.MarshalData(fmt.Sprintf(`{"VoterSSN": "%s", "Candidate":"%s","ElectionType":"%s","ElectionDate":"%s", "TxID": "%s"}`, args[0], args[1], args[2], args[3], stub.GetTxID()), VotingChoice{})
+
+	// @notice the ballot records both the voter and the proxy
+	if len(args) == 5 && args[4] != "" {
+		choice := VotingChoice{VoterSSN: args[0], Proxy: args[4], Candidate: args[1], ElectionType: args[2], ElectionDate: args[3], TxID: stub.GetTxID()}
+		result, _ = json.Marshal(choice)
+	}
+
+	err = stub.PutState(choiceKey, result)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{choiceKey, err.Error()}))
+	}
+
+	receipt, err := s.appendBallot(stub, args[2], choiceKey, result)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receiptAsBytes, _ := json.Marshal(receipt)
+
+	return shim.Success(receiptAsBytes)
+}
+
+// args[0] : key image
+// args[1] : candidatePublic Key
+// args[2] : electionType
+// args[3] : today Date
+// @notice the key image takes the place of the ssn, a second ballot signed with the same key has the same image
+func (s *ElectChaincode) castRingBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	if len(args) != 4 {
-		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"giveVote", "4"}))
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castRingBallot", "4"}))
+	}
+
+	keyImage := args[0]
+	electionType := args[2]
+
+	imageKey, err := stub.CreateCompositeKey(c.KEY_IMAGE, []string{electionType, keyImage})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.KEY_IMAGE, keyImage, err.Error()}))
+	}
+
+	imageAsBytes, err := stub.GetState(imageKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{imageKey, err.Error()}))
+	}
+
+	if imageAsBytes != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_15", []string{keyImage}))
+	}
+
+	err = stub.PutState(imageKey, []byte{0x00})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{imageKey, err.Error()}))
+	}
+
+	choiceKey, err := stub.CreateCompositeKey(c.VOTING_CHOICE, []string{electionType, args[1], args[3], keyImage})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.VOTING_CHOICE, args[1], err.Error()}))
+	}
+
+	choice := VotingChoice{KeyImage: keyImage, Candidate: args[1], ElectionType: electionType, ElectionDate: args[3], TxID: stub.GetTxID()}
+	choiceAsBytes, _ := json.Marshal(choice)
+
+	err = stub.PutState(choiceKey, choiceAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{choiceKey, err.Error()}))
 	}
 
-	err := u.CreateCompKey(stub, c.VOTING_CHOICE, []string{args[2], args[1], args[3], args[0]})
+	receipt, err := s.appendBallot(stub, electionType, choiceKey, choiceAsBytes)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	result, _ := u.MarshalData(fmt.Sprintf(`{"VoterSSN": "%s", "Candidate":"%s","ElectionType":"%s","ElectionDate":"%s", "TxID": "%s"}`, args[0], args[1], args[2], args[3], stub.GetTxID()), VotingChoice{})
+	receiptAsBytes, _ := json.Marshal(receipt)
 
-	return shim.Success(result)
+	return shim.Success(receiptAsBytes)
 }
 
-// args[0] : electionType
-// args[1] : bookmark
-// args[2] : page size
-// args[3] :
-func (s *ElectChaincode) getVotingResults(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+// args[0] : ssn
+// args[1] : commitment
+// args[2] : electionType
+// args[3] : today Date
+// args[4] : ballot context [electionType-electionID]
+func (s *ElectChaincode) commitVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 5 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"commitVote", "5"}))
+	}
 
+	ssn := args[0]
+	electionType := args[2]
+
+	commitKey, err := stub.CreateCompositeKey(c.VOTING_COMMIT, []string{electionType, ssn})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.VOTING_COMMIT, ssn, err.Error()}))
+	}
+
+	commitAsBytes, err := stub.GetState(commitKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{commitKey, err.Error()}))
+	}
+
+	if commitAsBytes != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_14", []string{ssn}))
+	}
+
+	commitment := VotingCommitment{ssn, args[1], electionType, args[4], args[3], stub.GetTxID()}
+	commitAsBytes, _ = json.Marshal(commitment)
+
+	err = stub.PutState(commitKey, commitAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{commitKey, err.Error()}))
+	}
+
+	receipt, err := s.appendBallot(stub, electionType, commitKey, commitAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receiptAsBytes, _ := json.Marshal(receipt)
+
+	return shim.Success(receiptAsBytes)
+}
+
+// args[0] : ssn
+// args[1] : candidatePublic Key
+// args[2] : electionType
+// args[3] : salt
+func (s *ElectChaincode) revealVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	if len(args) != 4 {
-		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getVotingResults", "4"}))
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"revealVote", "4"}))
 	}
 
-	electionType := args[0]
-	bookmark := args[1]
+	ssn := args[0]
+	candidate := args[1]
+	electionType := args[2]
+	salt := args[3]
 
-	pageSize, err := strconv.ParseInt(args[2], 10, 32)
+	commitKey, err := stub.CreateCompositeKey(c.VOTING_COMMIT, []string{electionType, ssn})
 	if err != nil {
-		return shim.Error(msg.GetErrMsg("COM_ERR_20", []string{args[2], err.Error()}))
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.VOTING_COMMIT, ssn, err.Error()}))
 	}
 
-	dataIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(c.VOTING_CHOICE, []string{electionType}, int32(pageSize), bookmark)
+	commitAsBytes, err := stub.GetState(commitKey)
 	if err != nil {
-		return shim.Error(msg.GetErrMsg("ELECT_ERR_01", []string{err.Error()}))
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{commitKey, err.Error()}))
 	}
 
-	defer dataIterator.Close()
+	if commitAsBytes == nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_02", []string{ssn, electionType}))
+	}
+
+	commitment := VotingCommitment{}
+	err = json.Unmarshal(commitAsBytes, &commitment)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	if a.GetCommitment(commitment.Context, ssn, candidate, salt) != commitment.Commitment {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_04", []string{ssn}))
+	}
+
+	// @notice the commitment itself stays untouched, it is a leaf of the ballot log.
+	// The ballot is keyed by the salt only, nothing in the state links it to the ssn
+	ballotID := a.GetBallotID(commitment.Context, salt)
+
+	revealed, err := u.FindCompositeKey(stub, c.BALLOT, []string{electionType, ballotID})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if revealed != "" {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_03", []string{ssn}))
+	}
+
+	// @notice the result is frozen once the ballot log is closed
+	_, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if ballotLog.Closed {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_11", []string{electionType}))
+	}
+
+	ballot := Ballot{ballotID, candidate, electionType, stub.GetTxID()}
+	ballotAsBytes, _ := json.Marshal(ballot)
+
+	err = u.PutCompKey(stub, c.BALLOT, []string{electionType, ballotID, candidate}, ballotAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(ballotAsBytes)
+}
+
+// args[0] : ballot token
+// args[1] : candidatePublic Key
+// args[2] : electionType
+func (s *ElectChaincode) castTokenBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castTokenBallot", "3"}))
+	}
+
+	candidate := args[1]
+	electionType := args[2]
+	tokenHash := a.GetHash(args[0])
+
+	spentKey, err := stub.CreateCompositeKey(c.SPENT_TOKEN, []string{electionType, tokenHash})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.SPENT_TOKEN, tokenHash, err.Error()}))
+	}
+
+	spentAsBytes, err := stub.GetState(spentKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{spentKey, err.Error()}))
+	}
+
+	if spentAsBytes != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_05", []string{tokenHash}))
+	}
+
+	err = stub.PutState(spentKey, []byte{0x00})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{spentKey, err.Error()}))
+	}
+
+	ballot := Ballot{tokenHash, candidate, electionType, stub.GetTxID()}
+	ballotAsBytes, _ := json.Marshal(ballot)
+
+	ballotKey, err := stub.CreateCompositeKey(c.BALLOT, []string{electionType, tokenHash, candidate})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.BALLOT, tokenHash, err.Error()}))
+	}
+
+	err = stub.PutState(ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{ballotKey, err.Error()}))
+	}
+
+	receipt, err := s.appendBallot(stub, electionType, ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receiptAsBytes, _ := json.Marshal(receipt)
+
+	return shim.Success(receiptAsBytes)
+}
+
+// Checks whether ballots of the type were cast in the election
+func hasBallots(stub shim.ChaincodeStubInterface, objType, electionType string) (bool, error) {
+	ballotIterator, err := stub.GetStateByPartialCompositeKey(objType, []string{electionType})
+	if err != nil {
+		return false, errors.New(msg.GetErrMsg("COM_ERR_04", []string{err.Error()}))
+	}
+	defer ballotIterator.Close()
+
+	return ballotIterator.HasNext(), nil
+}
+
+// @notice secret ballots must be decrypted first, so a result is never partial
+// args[0] : electionType
+func (s *ElectChaincode) getVotingResults(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getVotingResults", "1"}))
+	}
+
+	electionType := args[0]
+
+	result := VotingResult{ElectionType: electionType, Votes: map[string]int{}}
+
+	// open ballots keep the candidate at position 1, revealed ballots at position 2
+	for objType, position := range map[string]int{c.VOTING_CHOICE: 1, c.BALLOT: 2} {
+		votes, err := u.GetAllCompositeKeys(stub, objType, []string{electionType})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	for dataIterator.HasNext() {
-		keyIterator, err := dataIterator.Next()
+		for _, vote := range votes {
+			_, keyParts, err := stub.SplitCompositeKey(vote)
+			if err != nil {
+				return shim.Error(msg.GetErrMsg("COM_ERR_07", []string{err.Error()}))
+			}
+
+			result.Votes[keyParts[position]]++
+			result.Total++
+		}
+	}
+
+	// @notice encrypted ballots only count once the aggregate has been decrypted
+	_, tally, err := s.getEncryptedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{c.ENCRYPTED_TALLY, err.Error()}))
+	}
+
+	if tally.Counts == nil {
+		hasBallots, err := hasBallots(stub, c.ENCRYPTED_BALLOT, electionType)
 		if err != nil {
-			return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+			return shim.Error(err.Error())
+		}
+
+		if hasBallots {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_18", []string{"Encrypted", electionType}))
 		}
+	}
+
+	for i, count := range tally.Counts {
+		result.Votes[tally.Candidates[i]] += count
+		result.Total += count
+	}
 
-		_, keyParts, err := stub.SplitCompositeKey(keyIterator.Key)
+	// @notice sealed ballots only count once decryptAndTally has run
+	_, sealedTally, err := s.getSealedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if sealedTally == nil {
+		hasBallots, err := hasBallots(stub, c.SEALED_BALLOT, electionType)
 		if err != nil {
-			return shim.Error(msg.GetErrMsg("COM_ERR_07", []string{err.Error()}))
+			return shim.Error(err.Error())
 		}
 
-		fmt.Println("keyParts usage", keyParts[0])
+		if hasBallots {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_18", []string{"Sealed", electionType}))
+		}
+	} else {
+		for candidate, count := range sealedTally.Counts {
+			result.Votes[candidate] += count
+			result.Total += count
+		}
+	}
 
+	// @notice the result is bound to the ballot log root once voting has closed
+	_, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	fmt.Println("bookmark", metadata.Bookmark)
-	return shim.Success(nil)
+	result.BallotRoot = ballotLog.Root
+	result.BallotCount = ballotLog.Size
+
+	resultAsBytes, _ := json.Marshal(result)
+
+	return shim.Success(resultAsBytes)
 }
@@ -0,0 +1,315 @@
+package elect_cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "../access"
+	c "../constants"
+	u "../keyUtils"
+	msg "../msg"
+)
+
+func (s *ElectChaincode) getEncryptedTally(stub shim.ChaincodeStubInterface, electionType string) (string, EncryptedTally, error) {
+	tally := EncryptedTally{ElectionType: electionType}
+
+	tallyKey, err := stub.CreateCompositeKey(c.ENCRYPTED_TALLY, []string{electionType})
+	if err != nil {
+		return "", tally, err
+	}
+
+	tallyAsBytes, err := stub.GetState(tallyKey)
+	if err != nil {
+		return "", tally, err
+	}
+
+	if tallyAsBytes != nil {
+		err = json.Unmarshal(tallyAsBytes, &tally)
+	}
+
+	return tallyKey, tally, err
+}
+
+// args[0] : ssn
+// args[1] : electionType
+// args[2] : ballot context
+// args[3] : election public key
+// args[4] : candidates [json array, ballot order]
+// args[5] : encrypted ballot [json]
+func (s *ElectChaincode) castEncryptedBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castEncryptedBallot", "6"}))
+	}
+
+	ssn := args[0]
+	electionType := args[1]
+	context := args[2]
+	pubKey := args[3]
+
+	var candidates []string
+	err := json.Unmarshal([]byte(args[4]), &candidates)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	ballot := a.EncryptedBallot{}
+	err = json.Unmarshal([]byte(args[5]), &ballot)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	ballotKey, err := stub.CreateCompositeKey(c.ENCRYPTED_BALLOT, []string{electionType, ssn})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.ENCRYPTED_BALLOT, ssn, err.Error()}))
+	}
+
+	ballotAsBytes, err := stub.GetState(ballotKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{ballotKey, err.Error()}))
+	}
+
+	if ballotAsBytes != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_14", []string{ssn}))
+	}
+
+	tallyKey, tally, err := s.getEncryptedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{c.ENCRYPTED_TALLY, err.Error()}))
+	}
+
+	// @notice the candidate list is frozen by the first ballot
+	if tally.Ballots == 0 {
+		tally.Candidates = candidates
+	} else if strings.Join(tally.Candidates, c.SEPARATOR) != strings.Join(candidates, c.SEPARATOR) {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_07", []string{electionType}))
+	}
+
+	err = a.VerifyBallot(pubKey, ballot, len(tally.Candidates), context)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_06", []string{err.Error()}))
+	}
+
+	tally.Ciphertexts, err = a.AddCiphertexts(tally.Ciphertexts, ballot.Ciphertexts)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_06", []string{err.Error()}))
+	}
+	tally.Ballots++
+
+	ballotAsBytes, _ = json.Marshal(ballot)
+
+	err = stub.PutState(ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{ballotKey, err.Error()}))
+	}
+
+	tallyAsBytes, _ := json.Marshal(tally)
+
+	err = stub.PutState(tallyKey, tallyAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{tallyKey, err.Error()}))
+	}
+
+	receipt, err := s.appendBallot(stub, electionType, ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receiptAsBytes, _ := json.Marshal(receipt)
+
+	return shim.Success(receiptAsBytes)
+}
+
+// args[0] : electionType
+// args[1] : ballot context
+// args[2] : election public key
+// args[3] : decryption shares [json array, one per candidate]
+func (s *ElectChaincode) decryptTally(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"decryptTally", "4"}))
+	}
+
+	electionType := args[0]
+	context := args[1]
+	pubKey := args[2]
+
+	var shares []a.DecryptionShare
+	err := json.Unmarshal([]byte(args[3]), &shares)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	tallyKey, tally, err := s.getEncryptedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{c.ENCRYPTED_TALLY, err.Error()}))
+	}
+
+	if tally.Counts != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_09", []string{electionType}))
+	}
+
+	if len(shares) != len(tally.Ciphertexts) {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_10", []string{strconv.Itoa(len(tally.Ciphertexts)), strconv.Itoa(len(shares))}))
+	}
+
+	tally.Counts = make([]int, len(shares))
+	for i, share := range shares {
+		if !a.VerifyShare(pubKey, tally.Ciphertexts[i], share, context) {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_08", []string{tally.Candidates[i], "Invalid Proof"}))
+		}
+
+		tally.Counts[i], err = a.DecryptCount(tally.Ciphertexts[i], share.D, tally.Ballots)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_08", []string{tally.Candidates[i], err.Error()}))
+		}
+	}
+
+	tallyAsBytes, _ := json.Marshal(tally)
+
+	err = stub.PutState(tallyKey, tallyAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{tallyKey, err.Error()}))
+	}
+
+	return shim.Success(tallyAsBytes)
+}
+
+// args[0] : electionType
+// args[1] : ballot context
+// args[2] : trustee index
+// args[3] : trustee verification key
+// args[4] : threshold
+// args[5] : partial decryption shares [json array, one per candidate]
+func (s *ElectChaincode) submitPartialDecryption(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 6 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"submitPartialDecryption", "6"}))
+	}
+
+	electionType := args[0]
+	context := args[1]
+	trusteeIndex := args[2]
+	verificationKey := args[3]
+
+	index, err := strconv.Atoi(trusteeIndex)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_15", []string{trusteeIndex, err.Error()}))
+	}
+
+	threshold, err := strconv.Atoi(args[4])
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_15", []string{args[4], err.Error()}))
+	}
+
+	var shares []a.DecryptionShare
+	err = json.Unmarshal([]byte(args[5]), &shares)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	tallyKey, tally, err := s.getEncryptedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{c.ENCRYPTED_TALLY, err.Error()}))
+	}
+
+	if tally.Counts != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_09", []string{electionType}))
+	}
+
+	if len(shares) != len(tally.Ciphertexts) {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_10", []string{strconv.Itoa(len(tally.Ciphertexts)), strconv.Itoa(len(shares))}))
+	}
+
+	for i, share := range shares {
+		if !a.VerifyShare(verificationKey, tally.Ciphertexts[i], share, context) {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_14", []string{trusteeIndex, fmt.Sprint("Invalid Proof for Candidate " + tally.Candidates[i])}))
+		}
+	}
+
+	partialKey, err := stub.CreateCompositeKey(c.PARTIAL_DECRYPTION, []string{electionType, trusteeIndex})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.PARTIAL_DECRYPTION, trusteeIndex, err.Error()}))
+	}
+
+	partialAsBytes, err := stub.GetState(partialKey)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{partialKey, err.Error()}))
+	}
+
+	if partialAsBytes != nil {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_27", []string{trusteeIndex, "Partial Decryption"}))
+	}
+
+	partialAsBytes, _ = json.Marshal(PartialDecryption{index, shares, stub.GetTxID()})
+
+	err = stub.PutState(partialKey, partialAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{partialKey, err.Error()}))
+	}
+
+	partialKeys, err := u.GetAllCompositeKeys(stub, c.PARTIAL_DECRYPTION, []string{electionType})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// @notice the range query does not see the current submission on the peer,
+	// it is taken from the arguments and skipped wherever the query returns it
+	partials := []PartialDecryption{{index, shares, stub.GetTxID()}}
+	for _, key := range partialKeys {
+		if len(partials) == threshold {
+			break
+		}
+
+		if key == partialKey {
+			continue
+		}
+
+		stored := PartialDecryption{}
+		storedAsBytes, err := stub.GetState(key)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{key, err.Error()}))
+		}
+		json.Unmarshal(storedAsBytes, &stored)
+
+		partials = append(partials, stored)
+	}
+
+	if len(partials) < threshold {
+		return shim.Success(partialAsBytes)
+	}
+
+	indices := make([]int, 0)
+	for _, partial := range partials {
+		indices = append(indices, partial.TrusteeIndex)
+	}
+
+	tally.Counts = make([]int, len(tally.Ciphertexts))
+	for i := range tally.Ciphertexts {
+		candidateShares := make([]string, 0)
+		for _, partial := range partials {
+			candidateShares = append(candidateShares, partial.Shares[i].D)
+		}
+
+		d, err := a.CombinePartialDecryptions(indices, candidateShares)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_08", []string{tally.Candidates[i], err.Error()}))
+		}
+
+		tally.Counts[i], err = a.DecryptCount(tally.Ciphertexts[i], d, tally.Ballots)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("ELECT_ERR_08", []string{tally.Candidates[i], err.Error()}))
+		}
+	}
+
+	tallyAsBytes, _ := json.Marshal(tally)
+
+	err = stub.PutState(tallyKey, tallyAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{tallyKey, err.Error()}))
+	}
+
+	return shim.Success(tallyAsBytes)
+}
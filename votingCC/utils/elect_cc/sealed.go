@@ -0,0 +1,189 @@
+package elect_cc
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "../access"
+	c "../constants"
+	msg "../msg"
+)
+
+func (s *ElectChaincode) getSealedTally(stub shim.ChaincodeStubInterface, electionType string) (string, *SealedTally, error) {
+	tallyKey, err := stub.CreateCompositeKey(c.SEALED_TALLY, []string{electionType})
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.SEALED_TALLY, electionType, err.Error()}))
+	}
+
+	tallyAsBytes, err := stub.GetState(tallyKey)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_10", []string{tallyKey, err.Error()}))
+	}
+
+	if tallyAsBytes == nil {
+		return tallyKey, nil, nil
+	}
+
+	tally := SealedTally{}
+	err = json.Unmarshal(tallyAsBytes, &tally)
+	if err != nil {
+		return "", nil, errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	return tallyKey, &tally, nil
+}
+
+// args[0] : electionType
+// args[1] : ballot context
+// args[2] : sealed choice
+// args[3] : today Date
+func (s *ElectChaincode) castSealedBallot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"castSealedBallot", "4"}))
+	}
+
+	electionType := args[0]
+	sealedChoice := args[2]
+
+	err := a.ValidateSealedChoice(sealedChoice)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_06", []string{err.Error()}))
+	}
+
+	// @notice the ballot ID is derived from the transaction, never from the ssn
+	ballotID := a.GetHash(stub.GetTxID() + sealedChoice)
+
+	ballot := SealedBallot{ballotID, sealedChoice, electionType, args[1], args[3], stub.GetTxID()}
+	ballotAsBytes, _ := json.Marshal(ballot)
+
+	ballotKey, err := stub.CreateCompositeKey(c.SEALED_BALLOT, []string{electionType, ballotID})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_08", []string{c.SEALED_BALLOT, ballotID, err.Error()}))
+	}
+
+	err = stub.PutState(ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{ballotKey, err.Error()}))
+	}
+
+	receipt, err := s.appendBallot(stub, electionType, ballotKey, ballotAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receiptAsBytes, _ := json.Marshal(receipt)
+
+	return shim.Success(receiptAsBytes)
+}
+
+// args[0] : electionType
+// args[1] : election public key
+// args[2] : candidates [json array]
+// args[3] : publish plaintexts [true / false]
+// @notice the authority private key is read from the transient map, so it never reaches the ledger
+func (s *ElectChaincode) decryptAndTally(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"decryptAndTally", "4"}))
+	}
+
+	electionType := args[0]
+	pubKey := args[1]
+
+	var candidates []string
+	err := json.Unmarshal([]byte(args[2]), &candidates)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_16", []string{err.Error()}))
+	}
+
+	privKey := string(transient[c.AUTHORITY_KEY])
+	if !a.MatchesElectionKey(privKey, pubKey) {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_16", []string{"transient \"" + c.AUTHORITY_KEY + "\" does not match the election key"}))
+	}
+
+	_, ballotLog, err := s.getBallotLog(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !ballotLog.Closed {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_12", []string{electionType}))
+	}
+
+	tallyKey, existing, err := s.getSealedTally(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if existing != nil {
+		return shim.Error(msg.GetErrMsg("ELECT_ERR_17", []string{electionType}))
+	}
+
+	isCandidate := map[string]bool{}
+	for _, candidate := range candidates {
+		isCandidate[candidate] = true
+	}
+
+	ballotIterator, err := stub.GetStateByPartialCompositeKey(c.SEALED_BALLOT, []string{electionType})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_04", []string{err.Error()}))
+	}
+	defer ballotIterator.Close()
+
+	tally := SealedTally{ElectionType: electionType, Counts: map[string]int{}, TxID: stub.GetTxID()}
+
+	type shuffled struct {
+		key    string
+		choice string
+	}
+	var plaintexts []shuffled
+
+	for ballotIterator.HasNext() {
+		record, err := ballotIterator.Next()
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+		}
+
+		ballot := SealedBallot{}
+		json.Unmarshal(record.Value, &ballot)
+		tally.Ballots++
+
+		choice, err := a.OpenSealedChoice(privKey, ballot.SealedChoice, ballot.Context)
+		if err != nil || !isCandidate[choice] {
+			tally.Invalid++
+			continue
+		}
+
+		tally.Counts[choice]++
+
+		// @notice every endorser must produce the same order, so the shuffle is keyed by the transaction
+		plaintexts = append(plaintexts, shuffled{a.GetHash(stub.GetTxID() + ballot.BallotID), choice})
+	}
+
+	if args[3] == "true" {
+		sort.Slice(plaintexts, func(i, j int) bool {
+			return plaintexts[i].key < plaintexts[j].key
+		})
+
+		for _, plaintext := range plaintexts {
+			tally.Plaintexts = append(tally.Plaintexts, plaintext.choice)
+		}
+	}
+
+	tallyAsBytes, _ := json.Marshal(tally)
+
+	err = stub.PutState(tallyKey, tallyAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{tallyKey, err.Error()}))
+	}
+
+	return shim.Success(tallyAsBytes)
+}
@@ -1,12 +1,136 @@
 package elect_cc
 
+import (
+	a "../access"
+)
+
 type ElectChaincode struct {
 }
 
 type VotingChoice struct {
 	VoterSSN     string `json:"VoterSSN"`
+	Proxy        string `json:"Proxy,omitempty"`
+	KeyImage     string `json:"KeyImage,omitempty"`
 	Candidate    string `json:"Candidate"`
 	ElectionType string `json:"ElectionType"`
 	ElectionDate string `json:"ElectionDate"`
 	TxID         string `json:"TxID"`
 }
+
+type VotingCommitment struct {
+	VoterSSN     string `json:"VoterSSN"`
+	Commitment   string `json:"Commitment"`
+	ElectionType string `json:"ElectionType"`
+	Context      string `json:"Context"`
+	ElectionDate string `json:"ElectionDate"`
+	TxID         string `json:"TxID"`
+}
+
+type Ballot struct {
+	BallotID     string `json:"BallotID"`
+	Candidate    string `json:"Candidate"`
+	ElectionType string `json:"ElectionType"`
+	TxID         string `json:"TxID"`
+}
+
+type VotingResult struct {
+	ElectionType string         `json:"ElectionType"`
+	Votes        map[string]int `json:"Votes"`
+	Total        int            `json:"Total"`
+	BallotRoot   string         `json:"BallotRoot"`
+	BallotCount  int            `json:"BallotCount"`
+}
+
+type EncryptedTally struct {
+	ElectionType string         `json:"ElectionType"`
+	Candidates   []string       `json:"Candidates"`
+	Ciphertexts  []a.Ciphertext `json:"Ciphertexts"`
+	Ballots      int            `json:"Ballots"`
+	Counts       []int          `json:"Counts"`
+}
+
+type SealedBallot struct {
+	BallotID     string `json:"BallotID"`
+	SealedChoice string `json:"SealedChoice"`
+	ElectionType string `json:"ElectionType"`
+	Context      string `json:"Context"`
+	ElectionDate string `json:"ElectionDate"`
+	TxID         string `json:"TxID"`
+}
+
+type SealedTally struct {
+	ElectionType string         `json:"ElectionType"`
+	Ballots      int            `json:"Ballots"`
+	Counts       map[string]int `json:"Counts"`
+	Invalid      int            `json:"Invalid"`
+	Plaintexts   []string       `json:"Plaintexts,omitempty"`
+	TxID         string         `json:"TxID"`
+}
+
+type BallotLog struct {
+	ElectionType string `json:"ElectionType"`
+	Size         int    `json:"Size"`
+	Root         string `json:"Root"`
+	Height       int    `json:"Height,omitempty"`
+	Closed       bool   `json:"Closed"`
+	TxID         string `json:"TxID"`
+}
+
+type BallotLeaf struct {
+	LeafIndex int    `json:"LeafIndex"`
+	LeafHash  string `json:"LeafHash"`
+	BallotKey string `json:"BallotKey"`
+}
+
+type AuditedBallot struct {
+	LeafIndex int    `json:"LeafIndex"`
+	LeafHash  string `json:"LeafHash"`
+	BallotKey string `json:"BallotKey"`
+	Ballot    string `json:"Ballot"`
+}
+
+type BallotPage struct {
+	Ballots             []AuditedBallot `json:"Ballots"`
+	FetchedRecordsCount int32           `json:"FetchedRecordsCount"`
+	Bookmark            string          `json:"Bookmark"`
+}
+
+type BallotReceipt struct {
+	ElectionType string `json:"ElectionType"`
+	LeafIndex    int    `json:"LeafIndex"`
+	LeafHash     string `json:"LeafHash"`
+	TxID         string `json:"TxID"`
+}
+
+type InclusionProof struct {
+	ElectionType string   `json:"ElectionType"`
+	LeafIndex    int      `json:"LeafIndex"`
+	LeafHash     string   `json:"LeafHash"`
+	TreeSize     int      `json:"TreeSize"`
+	Root         string   `json:"Root"`
+	AuditPath    []string `json:"AuditPath"`
+}
+
+type BallotOpening struct {
+	Candidate  string   `json:"Candidate"`
+	Salt       string   `json:"Salt"`
+	Randomness []string `json:"Randomness"`
+}
+
+type VoteVerification struct {
+	ElectionType string `json:"ElectionType"`
+	LeafIndex    int    `json:"LeafIndex"`
+	LeafHash     string `json:"LeafHash"`
+	Exists       bool   `json:"Exists"`
+	Included     bool   `json:"Included"`
+	Root         string `json:"Root"`
+	Counted      bool   `json:"Counted"`
+	CountedAs    string `json:"CountedAs"`
+	Message      string `json:"Message"`
+}
+
+type PartialDecryption struct {
+	TrusteeIndex int                 `json:"TrusteeIndex"`
+	Shares       []a.DecryptionShare `json:"Shares"`
+	TxID         string              `json:"TxID"`
+}
@@ -14,7 +14,9 @@ import (
 
 	a "../access"
 
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 func ConvertToBytes(str []string) []byte {
@@ -115,6 +117,14 @@ func FindCompositeKey(stub shim.ChaincodeStubInterface, objType string, args []s
 
 }
 
+func IsAfter(dateToCheck, date, dateFormat string) bool {
+
+	date1, _ := time.Parse(dateFormat, dateToCheck)
+	date2, _ := time.Parse(dateFormat, date)
+
+	return date1.After(date2)
+}
+
 func ValidateElectionPeriod(date1, date2 string) bool {
 
 	startDate, err := time.Parse("2006/01/02", date1)
@@ -212,21 +222,31 @@ func FindUserBySSN(stub shim.ChaincodeStubInterface, ssn string) (bool, string)
 
 func CreateCompKey(stub shim.ChaincodeStubInterface, objType string, args []string) error {
 
+	return PutCompKey(stub, objType, args, []byte{0x00})
+}
+
+func PutCompKey(stub shim.ChaincodeStubInterface, objType string, args []string, value []byte) error {
+
 	compositeKey, err := stub.CreateCompositeKey(objType, args)
 	if err != nil {
 		return errors.New(msg.GetErrMsg("COM_ERR_08", []string{objType, args[1], err.Error()}))
 	}
 
-	err = stub.PutState(compositeKey, []byte{0x00})
+	err = stub.PutState(compositeKey, value)
 	if err != nil {
 		return errors.New(msg.GetErrMsg("COM_ERR_09", []string{compositeKey, err.Error()}))
 	}
 	return nil
 }
 
-func GenerateKeys() (string, string, error) {
+func GenerateKeys(algorithm string) (string, string, error) {
+
+	scheme, err := a.GetScheme(algorithm)
+	if err != nil {
+		return "", "", err
+	}
 
-	keys, err := a.GenerateKeys()
+	keys, err := scheme.GenerateKeys()
 	if err != nil {
 		return "", "", errors.New(err.Error())
 	}
@@ -234,16 +254,261 @@ func GenerateKeys() (string, string, error) {
 	return keys.PrivateKey, keys.PublicKey, nil
 }
 
-func VerifyUser(key, data, R, S, x, y string) (bool, string, error) {
+// GetAlgorithm returns the signature algorithm stored with the account,
+// empty for accounts registered before algorithms were recorded
+func GetAlgorithm(stub shim.ChaincodeStubInterface, key string) string {
+	var account struct {
+		Algorithm string `json:"Algorithm"`
+	}
+
+	accountAsBytes, err := stub.GetState(key)
+	if err != nil || accountAsBytes == nil {
+		return ""
+	}
+
+	json.Unmarshal(accountAsBytes, &account)
+
+	return account.Algorithm
+}
+
+// IsRevoked checks whether a revocation record exists for the account
+func IsRevoked(stub shim.ChaincodeStubInterface, key string) bool {
+
+	keyResultsIterator, err := stub.GetStateByPartialCompositeKey(c.REVOKED_KEY, []string{key})
+	if err != nil {
+		return false
+	}
+	defer keyResultsIterator.Close()
+
+	return keyResultsIterator.HasNext()
+}
+
+// VerifyUser checks the signature of the account over the called function
+// and its args, see GetSignedPayload
+func VerifyUser(stub shim.ChaincodeStubInterface, key string, args []string, R, S, x, y string) (bool, string, error) {
+
+	err := a.ValidateAccount(key)
+	if err != nil {
+		return false, "", errors.New(msg.GetErrMsg("COM_ERR_24", []string{key, err.Error()}))
+	}
+
+	scheme, err := a.GetScheme(GetAlgorithm(stub, key))
+	if err != nil {
+		return false, "", err
+	}
+
+	function, _ := stub.GetFunctionAndParameters()
+	data := a.GetSignedPayload(function, args)
 
 	hash := a.GetHash(data)
-	pubKey := a.GetPubKeyFromXY(x, y)
+	pubKey := scheme.GetPublicKey(x, y)
 
-	if key != a.GenerateAccount(pubKey) {
+	if !a.IsAccountOf(key, pubKey) {
 		return false, "", errors.New(msg.GetErrMsg("COM_ERR_21", []string{key, x, y}))
 	}
 
-	isVerified := a.Verify(pubKey, hash, R, S)
+	if IsRevoked(stub, key) {
+		return false, hash, errors.New(msg.GetErrMsg("COM_ERR_23", []string{key}))
+	}
+
+	isVerified := scheme.Verify(pubKey, data, a.Signature{R: R, S: S})
 
 	return isVerified, hash, nil
 }
+
+// GetAttribute returns an attribute of the submitter enrollment certificate,
+// empty when there is none
+func GetAttribute(stub shim.ChaincodeStubInterface, attribute string) string {
+	value, found, err := cid.GetAttributeValue(stub, attribute)
+	if err != nil || !found {
+		return ""
+	}
+
+	return value
+}
+
+// GetTxTime returns the transaction timestamp set by the client, which every
+// endorsing peer reads the same, unlike its own clock
+func GetTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New(msg.GetErrMsg("COM_ERR_26", []string{err.Error()}))
+	}
+
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// GetIdentity returns the MSP ID and certificate subject of the submitter
+func GetIdentity(stub shim.ChaincodeStubInterface) (string, string, error) {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", err
+	}
+
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", "", err
+	}
+
+	if cert == nil {
+		return "", "", errors.New("submitter has no X.509 certificate")
+	}
+
+	return mspID, cert.Subject.String(), nil
+}
+
+// GetRole returns the role attribute of the submitter enrollment certificate
+func GetRole(stub shim.ChaincodeStubInterface) string {
+	return GetAttribute(stub, c.ROLE_ATTRIBUTE)
+}
+
+// HasRole checks the role attribute of the submitter enrollment certificate
+func HasRole(stub shim.ChaincodeStubInterface, role string) bool {
+	return GetRole(stub) == role
+}
+
+// HasAnyRole checks the submitter has one of the roles. Admins pass every
+// check, anyone passes when no roles are given.
+func HasAnyRole(stub shim.ChaincodeStubInterface, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+
+	value := GetRole(stub)
+	if value == "" {
+		return false
+	}
+
+	if value == c.ADMIN {
+		return true
+	}
+
+	for _, role := range roles {
+		if value == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Record is a state entry of a composite key listing
+type Record struct {
+	Key        string   `json:"Key"`
+	ObjectType string   `json:"ObjectType"`
+	Attributes []string `json:"Attributes"`
+	Value      string   `json:"Value"`
+}
+
+// Page is a page of a listing, Bookmark continues it
+type Page struct {
+	Records             []Record `json:"Records"`
+	FetchedRecordsCount int32    `json:"FetchedRecordsCount"`
+	Bookmark            string   `json:"Bookmark"`
+}
+
+// GetPageSize parses the page size argument of a paginated query
+func GetPageSize(arg string) (int32, error) {
+	pageSize, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil || pageSize < 1 {
+		return 0, errors.New(msg.GetErrMsg("COM_ERR_18", []string{arg, "page size must be a positive number"}))
+	}
+
+	return int32(pageSize), nil
+}
+
+// GetCompositeKeyPage lists the composite keys of the object type that start
+// with the attributes, one page at a time
+func GetCompositeKeyPage(stub shim.ChaincodeStubInterface, objType string, args []string, pageSize int32, bookmark string) (Page, error) {
+	keySearchIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(objType, args, pageSize, bookmark)
+	if err != nil {
+		return Page{}, errors.New(msg.GetErrMsg("COM_ERR_25", []string{objType, err.Error()}))
+	}
+
+	defer keySearchIterator.Close()
+
+	page := Page{Records: make([]Record, 0)}
+
+	for keySearchIterator.HasNext() {
+		keyRange, err := keySearchIterator.Next()
+		if err != nil {
+			return Page{}, errors.New(msg.GetErrMsg("COM_ERR_06", []string{err.Error()}))
+		}
+
+		objectType, attributes, err := stub.SplitCompositeKey(keyRange.Key)
+		if err != nil {
+			return Page{}, errors.New(msg.GetErrMsg("COM_ERR_07", []string{keyRange.Key}))
+		}
+
+		page.Records = append(page.Records, Record{keyRange.Key, objectType, attributes, string(keyRange.Value)})
+	}
+
+	if metadata != nil {
+		page.FetchedRecordsCount = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+
+	return page, nil
+}
+
+// Functions of elect_cc that only read state, the only ones a read only
+// function may call
+var readOnlyQueries = map[string]bool{
+	"getInclusionProof": true,
+	"verifyBallot":      true,
+	"getBallots":        true,
+	"listCompositeKeys": true,
+}
+
+// readOnlyStub refuses every state write of the function it is handed to
+type readOnlyStub struct {
+	shim.ChaincodeStubInterface
+	function string
+}
+
+// ReadOnly wraps the stub so the function cannot write state
+func ReadOnly(stub shim.ChaincodeStubInterface, function string) shim.ChaincodeStubInterface {
+	return &readOnlyStub{stub, function}
+}
+
+func (s *readOnlyStub) refuse(key string) error {
+	return errors.New(msg.GetErrMsg("ACC_ERR_09", []string{s.function, key}))
+}
+
+func (s *readOnlyStub) PutState(key string, value []byte) error {
+	return s.refuse(key)
+}
+
+func (s *readOnlyStub) DelState(key string) error {
+	return s.refuse(key)
+}
+
+func (s *readOnlyStub) SetStateValidationParameter(key string, ep []byte) error {
+	return s.refuse(key)
+}
+
+func (s *readOnlyStub) PutPrivateData(collection string, key string, value []byte) error {
+	return s.refuse(key)
+}
+
+func (s *readOnlyStub) DelPrivateData(collection, key string) error {
+	return s.refuse(key)
+}
+
+func (s *readOnlyStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return s.refuse(key)
+}
+
+// @notice the called chaincode writes with its own stub, so only queries are let through
+func (s *readOnlyStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	function := ""
+	if len(args) > 0 {
+		function = string(args[0])
+	}
+
+	if chaincodeName != c.CCNAME || !readOnlyQueries[function] {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_11", []string{s.function, function, chaincodeName}))
+	}
+
+	return s.ChaincodeStubInterface.InvokeChaincode(chaincodeName, args, channel)
+}
@@ -0,0 +1,135 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Append-only Merkle tree in the RFC 6962 layout: leaves and inner nodes are
+// hashed with distinct prefixes and a tree of n leaves is split at the
+// largest power of two smaller than n.
+
+func LeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{0x00}, data...))
+	return hash[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{0x01})
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// Root returns the tree head over the given leaf hashes
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+
+	k := split(len(leaves))
+	return nodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// AuditPath returns the sibling hashes from leaf `index` up to the root
+func AuditPath(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+
+	k := split(len(leaves))
+	if index < k {
+		return append(AuditPath(leaves[:k], index), Root(leaves[k:]))
+	}
+	return append(AuditPath(leaves[k:], index-k), Root(leaves[:k]))
+}
+
+// Levels returns the inner levels of the tree bottom up, the last one holds
+// the root. A node without a sibling moves up unchanged, which gives the same
+// tree as the split at the largest power of two.
+func Levels(leaves [][]byte) [][][]byte {
+	levels := make([][][]byte, 0)
+
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, nodeHash(level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
+
+// Node is the position of a hash in the tree, level 0 holds the leaves
+type Node struct {
+	Level int
+	Index int
+}
+
+// AuditPathNodes returns the positions of the AuditPath hashes of leaf
+// `index` in a tree of size leaves, so they can be read from Levels
+func AuditPathNodes(index, size int) []Node {
+	nodes := make([]Node, 0)
+
+	for level, width := 0, size; width > 1; level++ {
+		if sibling := index ^ 1; sibling < width {
+			nodes = append(nodes, Node{level, sibling})
+		}
+
+		index >>= 1
+		width = (width + 1) / 2
+	}
+
+	return nodes
+}
+
+func VerifyInclusion(leafHash []byte, index, size int, path [][]byte, root []byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+
+	fn, sn := index, size-1
+	hash := leafHash
+
+	for _, sibling := range path {
+		if sn == 0 {
+			return false
+		}
+
+		if fn&1 == 1 || fn == sn {
+			hash = nodeHash(sibling, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = nodeHash(hash, sibling)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(hash, root)
+}
@@ -0,0 +1,59 @@
+package merkle
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestInclusionProofs(test *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := make([][]byte, 0)
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, LeafHash([]byte("ballot"+strconv.Itoa(i))))
+		}
+
+		root := Root(leaves)
+
+		for i := 0; i < size; i++ {
+			path := AuditPath(leaves, i)
+
+			if !VerifyInclusion(leaves[i], i, size, path, root) {
+				test.Fatal("valid proof rejected, size", size, "leaf", i)
+			}
+
+			if size > 1 && VerifyInclusion(leaves[(i+1)%size], i, size, path, root) {
+				test.Fatal("proof accepted for another leaf, size", size, "leaf", i)
+			}
+		}
+	}
+}
+
+func TestLevels(test *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := make([][]byte, 0)
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, LeafHash([]byte("ballot"+strconv.Itoa(i))))
+		}
+
+		levels := append([][][]byte{leaves}, Levels(leaves)...)
+		if top := levels[len(levels)-1]; len(top) != 1 || !bytes.Equal(top[0], Root(leaves)) {
+			test.Fatal("root mismatch, size", size)
+		}
+
+		for i := 0; i < size; i++ {
+			path := AuditPath(leaves, i)
+			nodes := AuditPathNodes(i, size)
+
+			if len(nodes) != len(path) {
+				test.Fatal("path length mismatch, size", size, "leaf", i)
+			}
+
+			for j, node := range nodes {
+				if !bytes.Equal(levels[node.Level][node.Index], path[j]) {
+					test.Fatal("path mismatch, size", size, "leaf", i, "node", j)
+				}
+			}
+		}
+	}
+}
@@ -37,6 +37,10 @@ var errCodeMap = map[string]string{
 
 	"COM_ERR_21": "Public Keys Mismatch : %s, %s, %s",
 	"COM_ERR_22": "Failed to Verify : %s, %s",
+	"COM_ERR_23": "Key \"%s\" Has Been Revoked",
+	"COM_ERR_24": "Invalid Account \"%s\" : %s",
+	"COM_ERR_25": "GetStateByPartialCompositeKeyWithPagination for \"%s\" Failed : %s",
+	"COM_ERR_26": "Failed to Get the Transaction Timestamp : %s",
 
 	"VOT_ERR_01": "Duplicated SSN : \"%s\"",
 	"VOT_ERR_02": "Failed to Register New User : %s",
@@ -55,8 +59,78 @@ var errCodeMap = map[string]string{
 	"VOT_ERR_15": "Election \"%s\" Not Exist",
 	"VOT_ERR_16": "Invalid Voting Method : %s",
 	"VOT_ERR_17": "Election \"%s\" is Not Over: %s , %s ",
-
-	"ELECT_ERR_01": "GetStateByPartialCompositeKeyWithPagination Failed : %s",
+	"VOT_ERR_18": "Invalid Ballot Mode : \"%s\"",
+	"VOT_ERR_19": "\"%s\" Not Supported by \"%s\" Ballot Mode of Election \"%s\"",
+	"VOT_ERR_20": "Ballot Token for \"%s\" is Already Issued",
+	"VOT_ERR_21": "Invalid Election Key : %s",
+	"VOT_ERR_22": "Election Key of \"%s\" is Not Available",
+	"VOT_ERR_23": "Tally of \"%s\" Election is Decrypted by Trustees",
+	"VOT_ERR_24": "Trustees of \"%s\" Election are Already Registered",
+	"VOT_ERR_25": "Invalid Threshold \"%s\" for %s Trustees",
+	"VOT_ERR_26": "\"%s\" is Not a Trustee of \"%s\" Election",
+	"VOT_ERR_27": "Trustee \"%s\" Has Already Submitted %s",
+	"VOT_ERR_28": "Key \"%s\" Has Been Rotated to \"%s\"",
+	"VOT_ERR_29": "Not Authorized : %s",
+	"VOT_ERR_30": "Account \"%s\" Already Exists",
+	"VOT_ERR_31": "Invalid Age Attestation : %s",
+	"VOT_ERR_32": "Invalid Ring Signature : %s",
+	"VOT_ERR_33": "Ring of \"%s\" Election is Frozen Since %s",
+	"VOT_ERR_34": "Election Proposal \"%s\" Not Found",
+	"VOT_ERR_35": "Election Proposal \"%s\" is %s",
+	"VOT_ERR_36": "Organization \"%s\" Has Already Approved \"%s\"",
+	"VOT_ERR_37": "Invalid Quorum : \"%s\"",
+	"VOT_ERR_38": "Failed to Set Endorsement Policy of \"%s\" : %s",
+	"VOT_ERR_39": "Local Election \"%s\" Needs a Jurisdiction",
+	"VOT_ERR_40": "Voter \"%s\" Does Not Reside in \"%s\"",
+	"VOT_ERR_41": "Invalid Evidence \"%s\" : %s",
+	"VOT_ERR_42": "User \"%s\" is Not Verified : %s",
+	"VOT_ERR_43": "User \"%s\" is Already %s",
+	"VOT_ERR_44": "Delegation of \"%s\" for \"%s\" Election is %s",
+	"VOT_ERR_45": "No Delegation of \"%s\" to Proxy \"%s\"",
+	"VOT_ERR_46": "Delegation Chains Are Not Allowed : \"%s\" to \"%s\"",
+	"VOT_ERR_47": "Candidates of \"%s\" Election Are Nominated by Petition",
+	"VOT_ERR_48": "Election \"%s\" Has No Nomination Petitions",
+	"VOT_ERR_49": "Petition of \"%s\" is %s",
+	"VOT_ERR_50": "No Petition of \"%s\" for \"%s\" Election",
+	"VOT_ERR_51": "\"%s\" Has Already Endorsed \"%s\"",
+	"VOT_ERR_52": "Age of \"%s\" is Unknown : %s",
+	"VOT_ERR_53": "\"%s\" is Not an Enrolled Registrar Key",
+	"VOT_ERR_54": "Registrar Key \"%s\" is Already %s",
+	"VOT_ERR_55": "Reveal Period of \"%s\" Election Ending %s is %s",
+	"VOT_ERR_56": "Votes of \"%s\" Election Are Already Counted",
+	"VOT_ERR_57": "Proxy \"%s\" Has No Bound Identity",
+	"VOT_ERR_58": "Candidates of \"%s\" Election Are Frozen Since %s",
+
+	"ELECT_ERR_02": "Commitment of \"%s\" for \"%s\" Election Not Found",
+	"ELECT_ERR_03": "Vote of \"%s\" is Already Revealed",
+	"ELECT_ERR_04": "Commitment Mismatch for \"%s\"",
+	"ELECT_ERR_05": "Ballot Token is Already Spent : \"%s\"",
+	"ELECT_ERR_06": "Invalid Encrypted Ballot : %s",
+	"ELECT_ERR_07": "Candidate List Changed Since the First Ballot of \"%s\" Election",
+	"ELECT_ERR_08": "Invalid Decryption Share for Candidate \"%s\" : %s",
+	"ELECT_ERR_09": "Encrypted Tally of \"%s\" Election is Already Decrypted",
+	"ELECT_ERR_10": "Expected %s Decryption Shares, Got %s",
+	"ELECT_ERR_11": "Ballot Log of \"%s\" Election is Closed",
+	"ELECT_ERR_12": "Ballot Log of \"%s\" Election is Not Closed Yet",
+	"ELECT_ERR_13": "Leaf \"%s\" Not Found in Ballot Log of \"%s\" Election",
+	"ELECT_ERR_14": "Invalid Partial Decryption of Trustee \"%s\" : %s",
+	"ELECT_ERR_15": "Key Image \"%s\" Has Already Voted",
+	"ELECT_ERR_16": "Invalid Authority Key : %s",
+	"ELECT_ERR_17": "Sealed Ballots of \"%s\" Election Are Already Decrypted",
+	"ELECT_ERR_18": "%s Ballots of \"%s\" Election Are Not Decrypted Yet",
+
+	"ACC_ERR_01": "Access Denied : \"%s\" Requires One of the Roles %s, Caller Role \"%s\"",
+	"ACC_ERR_02": "Access Denied : \"%s\" Can Only Be Called Through \"%s\", Proposal Sent to \"%s\"",
+	"ACC_ERR_03": "Failed to Read the Signed Proposal : %s",
+	"ACC_ERR_04": "Access Denied : \"%s\" Policy for \"%s\" Elections Requires %s",
+	"ACC_ERR_05": "Invalid Policy : %s",
+	"ACC_ERR_06": "Identity \"%s\" is Already Bound to \"%s\"",
+	"ACC_ERR_07": "Access Denied : User \"%s\" is Bound to Another Client Identity",
+	"ACC_ERR_08": "Failed to Read the Client Identity : %s",
+	"ACC_ERR_09": "Access Denied : \"%s\" is Read Only, Refused to Write \"%s\"",
+	"ACC_ERR_10": "Access Denied : Officials of \"%s\" Cannot Manage Elections of \"%s\"",
+	"ACC_ERR_11": "Access Denied : \"%s\" is Read Only, Refused to Call \"%s\" of \"%s\"",
+	"ACC_ERR_12": "Access Denied : Officials of \"%s\" Cannot Approve Keys of \"%s\"",
 }
 
 func GetErrMsgParams(arr []string) []interface{} {
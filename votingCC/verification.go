@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	c "./utils/constants"
+	u "./utils/keyUtils"
+	msg "./utils/msg"
+)
+
+// Users registered before verification was introduced are unverified
+func getVerificationStatus(user User) string {
+	if user.Verification == "" {
+		return c.UNVERIFIED
+	}
+
+	return user.Verification
+}
+
+func checkVerified(user User) error {
+	status := getVerificationStatus(user)
+	if status != c.VERIFIED {
+		return errors.New(msg.GetErrMsg("VOT_ERR_42", []string{user.SSN, status}))
+	}
+
+	return nil
+}
+
+// Evidence is referenced by SHA-256 hashes, the documents stay off the ledger
+func parseEvidence(arg string) ([]string, error) {
+	var evidence []string
+	err := json.Unmarshal([]byte(arg), &evidence)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("VOT_ERR_41", []string{arg, err.Error()}))
+	}
+
+	if len(evidence) == 0 {
+		return nil, errors.New(msg.GetErrMsg("VOT_ERR_41", []string{arg, "no evidence"}))
+	}
+
+	for _, hash := range evidence {
+		hashAsBytes, err := hex.DecodeString(hash)
+		if err != nil || len(hashAsBytes) != 32 {
+			return nil, errors.New(msg.GetErrMsg("VOT_ERR_41", []string{hash, "expecting a hex SHA-256 hash"}))
+		}
+	}
+
+	return evidence, nil
+}
+
+// Returns the current account of the user and its record
+func (s *VotingChaincode) findUser(stub shim.ChaincodeStubInterface, ssn string) (string, User, error) {
+	user := User{}
+
+	found, account := u.FindUserBySSN(stub, ssn)
+	if !found {
+		return "", user, errors.New(msg.GetErrMsg("COM_ERR_14", []string{ssn}))
+	}
+
+	userAsBytes, err := stub.GetState(account)
+	if err != nil {
+		return "", user, errors.New(msg.GetErrMsg("COM_ERR_10", []string{account, err.Error()}))
+	}
+
+	json.Unmarshal(userAsBytes, &user)
+
+	return account, user, nil
+}
+
+// Verifies the signature of the call by an enrolled registrar key, records the
+// verification and sets the status of the user. detail is the evidence hashes
+// or the reason.
+func (s *VotingChaincode) setVerification(stub shim.ChaincodeStubInterface, account string, user User, status, detail string, args []string) ([]byte, error) {
+	ssn := user.SSN
+	registrar := args[2]
+	R := args[3]
+	S := args[4]
+
+	registrarKey, err := s.verifyRegistrar(stub, registrar, args[:3], R, S, args[5], args[6])
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := u.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationDate := now.Format("2006/01/02 15:04:05")
+	verification := Verification{
+		SSN:              ssn,
+		Status:           status,
+		Registrar:        registrar,
+		MSPID:            registrarKey.MSPID,
+		Subject:          registrarKey.Subject,
+		R:                R,
+		S:                S,
+		VerificationDate: verificationDate,
+		TxID:             stub.GetTxID()}
+
+	if status == c.VERIFIED {
+		verification.Evidence = strings.Split(detail, ",")
+	} else {
+		verification.Reason = detail
+	}
+
+	verificationAsBytes, _ := json.Marshal(verification)
+
+	err = u.PutCompKey(stub, c.VERIFICATION, []string{ssn, stub.GetTxID()}, verificationAsBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Verification = status
+	userAsBytes, _ := json.Marshal(user)
+
+	err = stub.PutState(account, userAsBytes)
+	if err != nil {
+		return nil, errors.New(msg.GetErrMsg("COM_ERR_09", []string{account, err.Error()}))
+	}
+
+	return verificationAsBytes, nil
+}
+
+// args[0] : ssn
+// args[1] : evidence [json array of hex SHA-256 hashes of the documents checked]
+// args[2] : registrar account
+// args[3] : R [signature of args[0..2] by the registrar]
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+// @notice revoked users can be verified again with new evidence
+func (s *VotingChaincode) verifyUser(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"verifyUser", "7"}))
+	}
+
+	ssn := args[0]
+
+	evidence, err := parseEvidence(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	account, user, err := s.findUser(stub, ssn)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if getVerificationStatus(user) == c.VERIFIED {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_43", []string{ssn, c.VERIFIED}))
+	}
+
+	verificationAsBytes, err := s.setVerification(stub, account, user, c.VERIFIED, strings.Join(evidence, ","), args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(verificationAsBytes)
+}
+
+// args[0] : ssn
+// args[1] : reason
+// args[2] : registrar account
+// args[3] : R [signature of args[0..2] by the registrar]
+// args[4] : S
+// args[5] : X
+// args[6] : Y
+// @notice the user can no longer register or vote, registrations already made stay on the ledger
+func (s *VotingChaincode) revokeVerification(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"revokeVerification", "7"}))
+	}
+
+	account, user, err := s.findUser(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = checkVerified(user)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	verificationAsBytes, err := s.setVerification(stub, account, user, c.VERIFICATION_REVOKED, args[1], args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(verificationAsBytes)
+}
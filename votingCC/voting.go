@@ -24,50 +24,171 @@ func (s *VotingChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	return shim.Success(nil)
 }
 
+// Route of an Invoke function and the roles allowed to call it, anyone when
+// empty. Admins may call every route. election is the index of the election
+// type argument the route policies are looked up by, -1 when there is none.
+// readOnly routes cannot write state and are left out of the audit log.
+type route struct {
+	handler  func(*VotingChaincode, shim.ChaincodeStubInterface, []string) pb.Response
+	roles    []string
+	election int
+	readOnly bool
+}
+
+var (
+	anyone     = []string{}
+	admins     = []string{c.ADMIN}
+	voters     = []string{c.VOTER}
+	registrars = []string{c.REGISTRAR}
+	officials  = []string{c.OFFICIAL}
+	auditors   = []string{c.AUDITOR}
+)
+
+var routes map[string]route
+
+// @notice set up in init since setPolicy looks routes up
+func init() {
+	routes = map[string]route{
+		"registerUser":      {(*VotingChaincode).registerUser, []string{c.REGISTRAR, c.VOTER}, -1, false},
+		"registerElection":  {(*VotingChaincode).registerElection, officials, 0, false},
+		"registerCandidate": {(*VotingChaincode).registerCandidate, []string{c.VOTER, c.REGISTRAR}, 0, false},
+		"registerVoter":     {(*VotingChaincode).registerVoter, []string{c.VOTER, c.REGISTRAR}, 1, false},
+		"attestAge":         {(*VotingChaincode).attestAge, registrars, -1, false},
+		"setResidence":      {(*VotingChaincode).setResidence, registrars, -1, false},
+
+		"enrollRegistrarKey": {(*VotingChaincode).enrollRegistrarKey, registrars, -1, false},
+		"removeRegistrarKey": {(*VotingChaincode).removeRegistrarKey, admins, -1, false},
+		"getRegistrarKey":    {(*VotingChaincode).getRegistrarKey, anyone, -1, true},
+
+		"verifyUser":         {(*VotingChaincode).verifyUser, registrars, -1, false},
+		"revokeVerification": {(*VotingChaincode).revokeVerification, registrars, -1, false},
+
+		"getUser": {(*VotingChaincode).getUser, anyone, -1, true},
+
+		"rotateKey":  {(*VotingChaincode).rotateKey, []string{c.VOTER, c.REGISTRAR, c.OFFICIAL}, -1, false},
+		"revokeKey":  {(*VotingChaincode).revokeKey, []string{c.VOTER, c.REGISTRAR}, -1, false},
+		"recoverKey": {(*VotingChaincode).recoverKey, registrars, -1, false},
+
+		"getUserVotingHistory": {(*VotingChaincode).getUserVotingHistory, []string{c.VOTER, c.REGISTRAR, c.AUDITOR}, -1, true},
+		"getAllUsers":          {(*VotingChaincode).getAllUsers, []string{c.REGISTRAR, c.AUDITOR}, -1, true},
+
+		"vote": {(*VotingChaincode).vote, voters, 1, false},
+
+		"delegateVote":     {(*VotingChaincode).delegateVote, voters, 1, false},
+		"revokeDelegation": {(*VotingChaincode).revokeDelegation, voters, 1, false},
+
+		"setNominationRules": {(*VotingChaincode).setNominationRules, officials, 0, false},
+		"openPetition":       {(*VotingChaincode).openPetition, voters, 0, false},
+		"endorsePetition":    {(*VotingChaincode).endorsePetition, voters, 0, false},
+		"getPetition":        {(*VotingChaincode).getPetition, anyone, 0, true},
+
+		"revealVote":              {(*VotingChaincode).revealVote, voters, 1, false},
+		"issueBallotToken":        {(*VotingChaincode).issueBallotToken, officials, 1, false},
+		"castBallot":              {(*VotingChaincode).castBallot, anyone, 0, false},
+		"castRingBallot":          {(*VotingChaincode).castRingBallot, anyone, 0, false},
+		"getBallotCandidates":     {(*VotingChaincode).getBallotCandidates, anyone, 0, true},
+		"decryptTally":            {(*VotingChaincode).decryptTally, officials, 0, false},
+		"decryptAndTally":         {(*VotingChaincode).decryptAndTally, officials, 0, false},
+		"registerTrustees":        {(*VotingChaincode).registerTrustees, officials, 0, false},
+		"submitKeyCommitments":    {(*VotingChaincode).submitKeyCommitments, officials, 0, false},
+		"submitPartialDecryption": {(*VotingChaincode).submitPartialDecryption, officials, 0, false},
+		"joinRing":                {(*VotingChaincode).joinRing, voters, 0, false},
+		"getRing":                 {(*VotingChaincode).getRing, anyone, 0, true},
+		"getInclusionProof":       {(*VotingChaincode).getInclusionProof, anyone, 0, true},
+		"verifyMyVote":            {(*VotingChaincode).verifyMyVote, anyone, 0, true},
+
+		"countVotes": {(*VotingChaincode).countVotes, officials, 1, false},
+
+		"approveElection":     {(*VotingChaincode).approveElection, officials, 0, false},
+		"getPendingElections": {(*VotingChaincode).getPendingElections, anyone, 0, true},
+		"setElectionQuorum":   {(*VotingChaincode).setElectionQuorum, admins, -1, false},
+
+		"setElectionEndorsement": {(*VotingChaincode).setElectionEndorsement, officials, 0, false},
+		"getElectionEndorsement": {(*VotingChaincode).getElectionEndorsement, anyone, 0, true},
+		"setAuditorOrgs":         {(*VotingChaincode).setAuditorOrgs, admins, -1, false},
+
+		"setPolicy": {(*VotingChaincode).setPolicy, admins, -1, false},
+		"getPolicy": {(*VotingChaincode).getPolicy, anyone, -1, true},
+
+		"getAuditLog":       {(*VotingChaincode).getAuditLog, auditors, -1, true},
+		"getVoterRoll":      {(*VotingChaincode).getVoterRoll, auditors, 0, true},
+		"getBallots":        {(*VotingChaincode).getBallots, auditors, 0, true},
+		"listCompositeKeys": {(*VotingChaincode).listCompositeKeys, auditors, -1, true},
+	}
+}
+
 func (s *VotingChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 
 	function, args := stub.GetFunctionAndParameters()
 
-	if function == "registerUser" {
-		return s.registerUser(stub, args)
+	route, found := routes[function]
+	if !found {
+		return shim.Error(msg.GetErrMsg("COM_ERR_11", []string{function}))
+	}
+
+	if !u.HasAnyRole(stub, route.roles) {
+		return shim.Error(msg.GetErrMsg("ACC_ERR_01", []string{function, strings.Join(route.roles, ", "), u.GetRole(stub)}))
+	}
+
+	electionType := ""
+	if route.election >= 0 && route.election < len(args) {
+		electionType = args[route.election]
+	}
 
-	} else if function == "registerElection" {
-		return s.registerElection(stub, args)
-	} else if function == "registerCandidate" {
-		return s.registerCandidate(stub, args)
-	} else if function == "registerVoter" {
-		return s.registerVoter(stub, args)
+	err := s.checkPolicies(stub, function, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	} else if function == "getUser" {
-		return s.getUser(stub, args)
+	if route.readOnly {
+		return route.handler(s, u.ReadOnly(stub, function), args)
+	}
 
-	} else if function == "getUserVotingHistory" {
-		return s.getUserVotingHistory(stub, args)
-	} else if function == "getAllUsers" {
-		return s.getAllUsers(stub, args)
+	if electionType != "" {
+		err = s.checkElectionJurisdiction(stub, electionType)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
 
-	} else if function == "vote" {
-		return s.vote(stub, args)
+	response := route.handler(s, stub, args)
+	if response.Status != shim.OK || !isAdministrative(stub) {
+		return response
+	}
 
-	} else if function == "countVotes" {
-		return s.countVotes(stub, args)
+	err = s.logAudit(stub, function, args)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	return shim.Error(msg.GetErrMsg("COM_ERR_11", []string{function}))
+	return response
 }
 
 // args[0] : SSN
 // args[1] : FirstName
 // args[2] : LastName
-// args[3] : DateOfBirth
+// args[3] : DateOfBirth [optional, empty when the age is attested by a registrar]
 // args[4] : Gender
+// args[5] : signature algorithm [optional, p256 by default / ed25519 / secp256k1]
+// args[6] : bind to the submitter identity [optional, true / false, false by default]
+// @notice voters can only register themselves, bound to their own identity
 func (s *VotingChaincode) registerUser(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 5 {
-		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"registerUser", "5"}))
+	if len(args) < 5 || len(args) > 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"registerUser", "5 to 7"}))
 	}
 
 	ssn := args[0]
 	gender := args[4]
+	algorithm := a.P256
+	if len(args) > 5 && args[5] != "" {
+		algorithm = args[5]
+	}
+
+	bindIdentity := len(args) == 7 && args[6] == "true"
+	if !bindIdentity && !u.HasAnyRole(stub, []string{c.REGISTRAR}) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_29", []string{"registerUser without identity binding requires a registrar"}))
+	}
+
 	registrationDate := string(time.Now().UTC().Format("2006/01/02 15:04:05"))
 
 	found, _ := u.FindUserBySSN(stub, ssn)
@@ -81,15 +202,32 @@ func (s *VotingChaincode) registerUser(stub shim.ChaincodeStubInterface, args []
 		return shim.Error(msg.GetErrMsg("COM_ERR_18", []string{"gender", gender}))
 	}
 
-	privKey, pubKey, err := u.GenerateKeys()
+	privKey, pubKey, err := u.GenerateKeys(algorithm)
 	if err != nil {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_03", []string{err.Error()}))
 	}
 
 	account := a.GenerateAccount(pubKey)
 
-	userAsBytes, _ := u.MarshalData(fmt.Sprintf(`{"SSN": "%s", "PublicKey":"%s","FirstName":"%s","LastName":"%s","DateOfBirth":"%s","Gender":"%s","Election":"%s","RegistrationDate":"%s"}`,
-		ssn, account, args[1], args[2], args[3], gender, "", registrationDate), User{})
+	user := User{
+		SSN:              ssn,
+		PublicKey:        account,
+		FirstName:        args[1],
+		LastName:         args[2],
+		DateOfBirth:      args[3],
+		Gender:           gender,
+		RegistrationDate: registrationDate,
+		Algorithm:        algorithm,
+		Verification:     c.UNVERIFIED}
+
+	if bindIdentity {
+		user.MSPID, user.Subject, err = s.bindIdentity(stub, ssn)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	userAsBytes, _ := json.Marshal(user)
 
 	err = stub.PutState(account, userAsBytes)
 	if err != nil {
@@ -101,7 +239,7 @@ func (s *VotingChaincode) registerUser(stub shim.ChaincodeStubInterface, args []
 		return shim.Error(err.Error())
 	}
 
-	result, _ := u.MarshalData(fmt.Sprintf(`{"ssn": "%s", "PublicKey":"%s","PrivateKey":"%s","RegistrationDate":"%s"}`, ssn, account, privKey, registrationDate), NewUser{})
+	result, _ := u.MarshalData(fmt.Sprintf(`{"ssn": "%s", "PublicKey":"%s","PrivateKey":"%s","RegistrationDate":"%s","Algorithm":"%s"}`, ssn, account, privKey, registrationDate, algorithm), NewUser{})
 
 	return shim.Success(result)
 }
@@ -110,21 +248,73 @@ func (s *VotingChaincode) registerUser(stub shim.ChaincodeStubInterface, args []
 // args[1] : electionID
 // args[2] : start date
 // args[3] : end date
+// args[4] : ballot mode [optional, open by default]
+// args[5] : election authority public key [blind-token : RSA, homomorphic : P-256 ElGamal, ecies : P-256]
+// args[6] : jurisdiction [optional, national by default, required for local elections]
+// @notice the election stays pending until officials of the quorum of organizations approve it
 func (s *VotingChaincode) registerElection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	if len(args) != 4 {
-		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"registerElection", "4"}))
+	if len(args) < 4 || len(args) > 7 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"registerElection", "4 to 7"}))
 	}
 
 	electionType := args[0]
 	electionID := args[1]
 	startDate := args[2]
 	endDate := args[3]
+	ballotMode := c.OPEN
+	electionKey := ""
+	jurisdiction := c.NATIONAL
+
+	if len(args) > 4 {
+		ballotMode = args[4]
+	}
+
+	if len(args) > 5 {
+		electionKey = args[5]
+	}
+
+	if len(args) > 6 && args[6] != "" {
+		jurisdiction = args[6]
+	}
 
 	if electionType != c.PRIMARY && electionType != c.GENERAL && electionType != c.LOCAL {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_04", []string{electionType}))
 	}
 
+	if electionType == c.LOCAL && jurisdiction == c.NATIONAL {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_39", []string{electionID}))
+	}
+
+	err := checkJurisdiction(stub, jurisdiction)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	switch ballotMode {
+	case c.OPEN, c.COMMIT_REVEAL, c.RING:
+	case c.BLIND_TOKEN:
+		_, err := a.ParseRSAPublicKey(electionKey)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+		}
+	case c.HOMOMORPHIC:
+		// @notice without a key the election key is generated later by registered trustees
+		if electionKey != "" {
+			err := a.ValidatePoint(electionKey)
+			if err != nil {
+				return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+			}
+		}
+	case c.ECIES:
+		err := a.ValidatePoint(electionKey)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_21", []string{err.Error()}))
+		}
+	default:
+		return shim.Error(msg.GetErrMsg("VOT_ERR_18", []string{ballotMode}))
+	}
+
 	isValid := u.ValidateElectionPeriod(startDate, endDate)
 	if isValid != true {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_05", []string{startDate, endDate}))
@@ -139,17 +329,143 @@ func (s *VotingChaincode) registerElection(stub shim.ChaincodeStubInterface, arg
 		return shim.Error(err.Error())
 	}
 
-	err = u.CreateCompKey(stub, c.ELECTION, []string{electionType, startDate, endDate, electionID})
+	election := Election{ID: electionID, PublicKey: electionKey, ElectionType: electionType,
+		ElectionPeriod: fmt.Sprint(startDate + " - " + endDate), BallotMode: ballotMode, Jurisdiction: jurisdiction}
+
+	proposal, err := s.proposeElection(stub, election, startDate, endDate)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	newElection := NewElection{electionType, electionID, startDate, endDate, stub.GetTxID()}
+	newElection := NewElection{electionType, electionID, startDate, endDate, ballotMode, proposal.Status, jurisdiction, stub.GetTxID()}
 	newElectionJSON, _ := json.Marshal(newElection)
 
 	return shim.Success(newElectionJSON)
 }
 
+// Returns the election composite key, its split attributes
+// [ electionType, startDate, endDate, electionID ] and the election record.
+func (s *VotingChaincode) findElection(stub shim.ChaincodeStubInterface, electionType string) (string, []string, Election, error) {
+	election, err := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
+	if err != nil {
+		return "", nil, Election{}, err
+	}
+
+	if election == "" {
+		return "", nil, Election{}, errors.New(msg.GetErrMsg("VOT_ERR_15", []string{electionType}))
+	}
+
+	_, keyParts, err := stub.SplitCompositeKey(election)
+	if err != nil {
+		return "", nil, Election{}, errors.New(msg.GetErrMsg("COM_ERR_07", []string{election}))
+	}
+
+	electionInfo, err := s.getElection(stub, election)
+	if err != nil {
+		return "", nil, Election{}, err
+	}
+
+	return election, keyParts, electionInfo, nil
+}
+
+// Elections registered before ballot modes were introduced hold no record
+// under their composite key and are treated as open elections.
+func (s *VotingChaincode) getElection(stub shim.ChaincodeStubInterface, electionKey string) (Election, error) {
+	election := Election{BallotMode: c.OPEN}
+
+	electionAsBytes, err := stub.GetState(electionKey)
+	if err != nil {
+		return election, errors.New(msg.GetErrMsg("COM_ERR_10", []string{electionKey, err.Error()}))
+	}
+
+	json.Unmarshal(electionAsBytes, &election)
+
+	return election, nil
+}
+
+// Returns the voter account, record and age once the voter is registered,
+// old enough and has not voted yet.
+func (s *VotingChaincode) getEligibleVoter(stub shim.ChaincodeStubInterface, voterSSN string) (string, User, string, error) {
+	voter := User{}
+
+	found, voterPubKey := u.FindUserBySSN(stub, voterSSN)
+	if !found {
+		return "", voter, "", errors.New(msg.GetErrMsg("COM_ERR_14", []string{voterSSN}))
+	}
+
+	voterAsBytes, err := stub.GetState(voterPubKey)
+	if err != nil {
+		return "", voter, "", errors.New(msg.GetErrMsg("COM_ERR_10", []string{voterSSN, err.Error()}))
+	}
+
+	err = json.Unmarshal(voterAsBytes, &voter)
+	if err != nil {
+		return "", voter, "", errors.New(msg.GetErrMsg("COM_ERR_02", []string{err.Error()}))
+	}
+
+	hasVoted := strings.Contains(voter.Election, c.VOTED)
+	if hasVoted == true {
+		return "", voter, "", errors.New(msg.GetErrMsg("VOT_ERR_14", []string{voterSSN}))
+	}
+
+	err = checkVerified(voter)
+	if err != nil {
+		return "", voter, "", err
+	}
+
+	isRegistered := strings.Contains(voter.Election, c.REGISTERED)
+	if isRegistered != true {
+		return "", voter, "", errors.New(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Voter" + voterSSN + " Not Registered")}))
+	}
+
+	isEligibleToVote := strings.Split(voter.Election, c.SEPARATOR)
+	if isEligibleToVote[6] != "true" {
+		return "", voter, "", errors.New(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint(isEligibleToVote[5] + " Voter Min Age " + strconv.Itoa(c.VOTER_MIN_AGE))}))
+	}
+
+	return voterPubKey, voter, isEligibleToVote[5], nil
+}
+
+// Checks the user registered for the election of the type, whether or not
+// the user has voted since
+func isRegisteredFor(user User, electionType string) bool {
+	return strings.HasPrefix(user.Election, c.REGISTERED+c.SEPARATOR+electionType+c.SEPARATOR) ||
+		strings.HasPrefix(user.Election, c.VOTED+c.SEPARATOR+electionType+c.SEPARATOR)
+}
+
+func (s *VotingChaincode) getCandidate(stub shim.ChaincodeStubInterface, electionType, candidatePubKey string) (User, error) {
+	candidate := User{}
+
+	err := a.ValidateAccount(candidatePubKey)
+	if err != nil {
+		return candidate, errors.New(msg.GetErrMsg("COM_ERR_24", []string{candidatePubKey, err.Error()}))
+	}
+
+	candidateAsBytes, err := stub.GetState(candidatePubKey)
+	if err != nil {
+		return candidate, errors.New(msg.GetErrMsg("COM_ERR_10", []string{candidatePubKey, err.Error()}))
+	}
+
+	json.Unmarshal(candidateAsBytes, &candidate)
+
+	candidateCompKey, err := stub.CreateCompositeKey(c.CANDIDATE, []string{electionType, candidate.SSN})
+	if err != nil {
+		return candidate, errors.New(msg.GetErrMsg("COM_ERR_08", []string{c.CANDIDATE, candidate.SSN, err.Error()}))
+	}
+
+	candidateKeyAsBytes, _ := stub.GetState(candidateCompKey)
+	if candidate.SSN == "" || candidateKeyAsBytes == nil {
+		return candidate, errors.New(msg.GetErrMsg("VOT_ERR_12", []string{candidatePubKey, "Not Registered"}))
+	}
+
+	// @notice votes go to the current account only, so the tally is not split
+	if candidate.RotatedTo != "" {
+		return candidate, errors.New(msg.GetErrMsg("VOT_ERR_28", []string{candidatePubKey, candidate.RotatedTo}))
+	}
+
+	return candidate, nil
+}
+
 // args[0] : election Type
 func (s *VotingChaincode) getCandidates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	if len(args) != 1 {
@@ -186,10 +502,10 @@ func (s *VotingChaincode) registerCandidate(stub shim.ChaincodeStubInterface, ar
 		return shim.Error(msg.GetErrMsg("VOT_ERR_04", []string{electionType}))
 	}
 
-	isVerified, hash, err := u.VerifyUser(pubKey, electionType, R, S, X, Y)
+	isVerified, hash, err := u.VerifyUser(stub, pubKey, args[:2], R, S, X, Y)
 	if !isVerified {
 		return shim.Error(msg.GetErrMsg("COM_ERR_22", []string{fmt.Sprint("Hash: " + hash +
-			" R: " + R + " S: " + S), err.Error()}))
+			" R: " + R + " S: " + S), fmt.Sprint(err)}))
 	}
 
 	election, _ := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
@@ -205,6 +521,21 @@ func (s *VotingChaincode) registerCandidate(stub shim.ChaincodeStubInterface, ar
 	electionStartDate := keyParts[1]
 	electionEndDate := keyParts[2]
 
+	// @notice candidates of elections in nomination mode are registered by petition
+	_, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.NominationThreshold > 0 {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_47", []string{electionType}))
+	}
+
+	err = checkCandidatesOpen(electionType, electionStartDate, string(time.Now().UTC().Format("2006/01/02")))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	userAsBytes, err := stub.GetState(pubKey)
 	if err != nil {
 		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{pubKey, err.Error()}))
@@ -213,13 +544,25 @@ func (s *VotingChaincode) registerCandidate(stub shim.ChaincodeStubInterface, ar
 	user := User{}
 	json.Unmarshal(userAsBytes, &user)
 
+	if user.RotatedTo != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_28", []string{pubKey, user.RotatedTo}))
+	}
+
+	err = checkVerified(user)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	candidateCompKey := fmt.Sprintf("\x00" + c.CANDIDATE + "\x00" + electionType + "\x00" + user.SSN + "\x00")
 	candidateKeyAsBytes, _ := stub.GetState(candidateCompKey)
 	if candidateKeyAsBytes != nil {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_09", []string{candidateCompKey}))
 	}
 
-	age, isEligibleCandidate := u.ValidateAge(user.DateOfBirth, "2006/01/02", electionStartDate, electionEndDate, c.CANDIDATE_MIN_AGE)
+	age, isEligibleCandidate, err := s.checkAge(stub, user, electionStartDate, electionEndDate, c.CANDIDATE_MIN_AGE)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	if !isEligibleCandidate {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint(age + " Candidate Min Age " + strconv.Itoa(c.CANDIDATE_MIN_AGE))}))
@@ -269,6 +612,11 @@ func (s *VotingChaincode) registerVoter(stub shim.ChaincodeStubInterface, args [
 	user := User{}
 	json.Unmarshal(userAsBytes, &user)
 
+	err = checkVerified(user)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	election, _ := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
 	if election == "" {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_07", []string{electionType}))
@@ -290,7 +638,19 @@ func (s *VotingChaincode) registerVoter(stub shim.ChaincodeStubInterface, args [
 		return shim.Error(msg.GetErrMsg("VOT_ERR_10", []string{ssn}))
 	}
 
-	age, isEligibleToVote := u.ValidateAge(user.DateOfBirth, "2006/01/02", electionStartDate, electionEndDate, c.VOTER_MIN_AGE)
+	electionRecord, err := s.getElection(stub, election)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isResident(user, electionRecord) {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_40", []string{ssn, getJurisdiction(electionRecord)}))
+	}
+
+	age, isEligibleToVote, err := s.checkAge(stub, user, electionStartDate, electionEndDate, c.VOTER_MIN_AGE)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	candidateCompKey := fmt.Sprintf("\x00" + c.CANDIDATE + "\x00" + electionType + "\x00" + ssn + "\x00")
 	candidateKeyAsBytes, _ := stub.GetState(candidateCompKey)
@@ -349,6 +709,13 @@ func (s *VotingChaincode) getUser(stub shim.ChaincodeStubInterface, args []strin
 		}
 	}
 
+	if queryType == c.USERKEY {
+		err := a.ValidateAccount(user)
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_24", []string{user, err.Error()}))
+		}
+	}
+
 	userAsBytes, err := stub.GetState(user)
 	if err != nil {
 		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{user, err.Error()}))
@@ -360,10 +727,11 @@ func (s *VotingChaincode) getUser(stub shim.ChaincodeStubInterface, args []strin
 
 // args[0] : ssn
 // args[1] : election type
-// args[2] : candidate pub key
+// args[2] : candidate pub key [open] / commitment [commit-reveal] / encrypted ballot [homomorphic] / sealed choice [ecies]
+// args[3] : proxy ssn [optional, open, the voter of args[0] delegated the vote to the proxy]
 func (s *VotingChaincode) vote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"vote", "3"}))
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"vote", "3 or 4"}))
 	}
 
 	todayDate := string(time.Now().UTC().Format("2006/01/02"))
@@ -386,89 +754,268 @@ func (s *VotingChaincode) vote(stub shim.ChaincodeStubInterface, args []string)
 		return shim.Error(msg.GetErrMsg("COM_ERR_07", []string{election}))
 	}
 
+	electionInfo, err := s.getElection(stub, election)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	isElectionPeriod := u.IsWithinRange(todayDate, keyParts[1], keyParts[2], "2006/01/02")
 	if isElectionPeriod != true {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_13", []string{todayDate, electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2])}))
 	}
 
-	found, voterPubKey := u.FindUserBySSN(stub, voterSSN)
-	if !found {
-		return shim.Error(msg.GetErrMsg("COM_ERR_14", []string{voterSSN}))
+	// @notice ring ballots are cast through castRingBallot, from an identity not linked to the voter
+	if electionInfo.BallotMode == c.RING {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"vote", electionInfo.BallotMode, electionType}))
 	}
 
-	voterAsBytes, err := stub.GetState(voterPubKey)
+	voterPubKey, voter, voterAge, err := s.getEligibleVoter(stub, voterSSN)
 	if err != nil {
-		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{voterSSN, err.Error()}))
+		return shim.Error(err.Error())
 	}
 
-	voter := User{}
-	err = json.Unmarshal(voterAsBytes, &voter)
+	proxySSN := ""
+	if len(args) == 4 {
+		proxySSN = args[3]
+	}
+
+	if proxySSN != "" {
+		if electionInfo.BallotMode != c.OPEN {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"vote by proxy", electionInfo.BallotMode, electionType}))
+		}
+
+		_, err = s.getProxy(stub, electionType, voterSSN, proxySSN)
+	} else {
+		// @notice a bound voter is authorized by the submitter identity
+		err = s.checkIdentity(stub, voter)
+	}
 
 	if err != nil {
-		return shim.Error("Failed to unmarshal voter")
+		return shim.Error(err.Error())
 	}
 
-	hasVoted := strings.Contains(voter.Election, c.VOTED)
-	if hasVoted == true {
-		return shim.Error(msg.GetErrMsg("VOT_ERR_14", []string{voterSSN}))
+	vote := Vote{
+		voterSSN,
+		proxySSN,
+		voter.FirstName,
+		voter.LastName,
+		voterAge,
+		candidatePubKey,
+		"",
+		"",
+		todayDate,
+		electionType,
+		0,
+		"",
+		stub.GetTxID()}
+
+	var receiptAsBytes []byte
+
+	switch electionInfo.BallotMode {
+	case c.COMMIT_REVEAL:
+		// @notice the candidate stays hidden until revealVote is called after the election
+		vote.Candidate = ""
+		vote.Commitment = args[2]
+
+		receiptAsBytes, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"commitVote", voter.SSN, vote.Commitment, electionType, todayDate, getBallotContext(electionInfo)})
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+		}
+	case c.OPEN:
+		candidate, err := s.getCandidate(stub, electionType, candidatePubKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		if candidate.SSN == voter.SSN {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_12", []string{candidatePubKey, fmt.Sprint("Same Voter " + voterSSN + " and Candidate " + candidate.SSN)}))
+		}
+
+		receiptAsBytes, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"giveVote", voter.SSN, candidatePubKey, electionType, todayDate, proxySSN})
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+		}
+	case c.HOMOMORPHIC:
+		vote.Candidate = ""
+
+		if electionInfo.PublicKey == "" {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_22", []string{electionType}))
+		}
+
+		candidates, err := s.getCandidateAccounts(stub, electionType)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		candidatesAsBytes, _ := json.Marshal(candidates)
+
+		receiptAsBytes, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"castEncryptedBallot", voter.SSN, electionType,
+			getBallotContext(electionInfo), electionInfo.PublicKey, string(candidatesAsBytes), args[2]})
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+		}
+	case c.ECIES:
+		// @notice elect_cc keeps only the sealed choice, not the ssn, until decryptAndTally
+		vote.Candidate = ""
+
+		receiptAsBytes, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"castSealedBallot", electionType,
+			getBallotContext(electionInfo), args[2], todayDate})
+		if err != nil {
+			return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+		}
+	default:
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"vote", electionInfo.BallotMode, electionType}))
 	}
 
-	isRegistered := strings.Contains(voter.Election, c.REGISTERED)
-	if isRegistered != true {
-		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint("Voter" + voterSSN + " Not Registered")}))
+	// @notice the receipt lets the voter request an inclusion proof once voting closes
+	receipt := BallotReceipt{}
+	json.Unmarshal(receiptAsBytes, &receipt)
+
+	vote.LeafIndex = receipt.LeafIndex
+	vote.LeafHash = receipt.LeafHash
+
+	voter.Election = strings.Replace(voter.Election, c.REGISTERED, c.VOTED, -1)
+
+	voterAsBytes, _ := json.Marshal(voter)
+
+	err = stub.PutState(voterPubKey, voterAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{voterPubKey, err.Error()}))
 	}
 
-	isEligibleToVote := strings.Split(voter.Election, c.SEPARATOR)
-	if isEligibleToVote[6] != "true" {
-		return shim.Error(msg.GetErrMsg("VOT_ERR_11", []string{fmt.Sprint(isEligibleToVote[5] + " Voter Min Age " + strconv.Itoa(c.VOTER_MIN_AGE))}))
+	// @notice the ballot is counted once, for the voter, whoever cast it
+	delegationStatus := c.DELEGATION_REVOKED
+	if proxySSN != "" {
+		delegationStatus = c.DELEGATION_USED
 	}
 
-	voterAge := isEligibleToVote[5]
+	err = s.closeDelegation(stub, electionType, voterSSN, delegationStatus)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	candidateAsBytes, err := stub.GetState(candidatePubKey)
+	voteJSON, _ := json.Marshal(vote)
+
+	return shim.Success(voteJSON)
+}
+
+// Last day commit-reveal votes of the election ending on endDate can be revealed
+func getRevealDeadline(endDate string) string {
+	end, _ := time.Parse("2006/01/02", endDate)
+
+	return end.AddDate(0, 0, c.REVEAL_DAYS).Format("2006/01/02")
+}
+
+// args[0] : ssn
+// args[1] : election type
+// args[2] : candidate pub key
+// args[3] : salt
+// @notice within REVEAL_DAYS after the election end date, before countVotes
+func (s *VotingChaincode) revealVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"revealVote", "4"}))
+	}
+
+	todayDate := string(time.Now().UTC().Format("2006/01/02"))
+
+	voterSSN := args[0]
+	electionType := args[1]
+	candidatePubKey := args[2]
+	salt := args[3]
+
+	election, err := u.FindCompositeKey(stub, c.ELECTION, []string{electionType})
 	if err != nil {
-		return shim.Error(msg.GetErrMsg("COM_ERR_10", []string{voterSSN, err.Error()}))
+		return shim.Error(err.Error())
 	}
 
-	candidate := User{}
-	json.Unmarshal(candidateAsBytes, &candidate)
+	if election == "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_15", []string{electionType}))
+	}
+
+	_, keyParts, err := stub.SplitCompositeKey(election)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_07", []string{election}))
+	}
+
+	electionInfo, err := s.getElection(stub, election)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if electionInfo.BallotMode != c.COMMIT_REVEAL {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_19", []string{"revealVote", electionInfo.BallotMode, electionType}))
+	}
 
-	isCandidate := strings.Split(candidate.Election, c.SEPARATOR)
-	if isCandidate[4] != "true" {
-		return shim.Error(msg.GetErrMsg("VOT_ERR_12", []string{candidatePubKey, "Not Registered"}))
+	isElectionOver := u.IsAfter(todayDate, keyParts[2], "2006/01/02")
+	if !isElectionOver {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_17", []string{electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2]), todayDate}))
+	}
+
+	revealDeadline := getRevealDeadline(keyParts[2])
+	if u.IsAfter(todayDate, revealDeadline, "2006/01/02") {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_55", []string{electionType, revealDeadline, "Over"}))
+	}
+
+	candidate, err := s.getCandidate(stub, electionType, candidatePubKey)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	if candidate.SSN == voter.SSN {
+	if candidate.SSN == voterSSN {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_12", []string{candidatePubKey, fmt.Sprint("Same Voter " + voterSSN + " and Candidate " + candidate.SSN)}))
 	}
 
-	_, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"giveVote", voter.SSN, candidatePubKey, electionType, todayDate})
+	ballot, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"revealVote", voterSSN, candidatePubKey, electionType, salt})
 	if err != nil {
 		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
 	}
 
-	voter.Election = strings.Replace(voter.Election, c.REGISTERED, c.VOTED, -1)
+	return shim.Success(ballot)
+}
 
-	voterAsBytes, _ = json.Marshal(voter)
+// args[0] : election type
+// args[1] : leaf index [from the vote receipt]
+func (s *VotingChaincode) getInclusionProof(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"getInclusionProof", "2"}))
+	}
 
-	err = stub.PutState(voterPubKey, voterAsBytes)
+	proof, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"getInclusionProof", args[0], args[1]})
 	if err != nil {
-		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{voterPubKey, err.Error()}))
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
 	}
 
-	vote := Vote{
-		voterSSN,
-		voter.FirstName,
-		voter.LastName,
-		voterAge,
-		candidatePubKey,
-		todayDate,
-		electionType,
-		stub.GetTxID()}
+	return shim.Success(proof)
+}
 
-	voteJSON, _ := json.Marshal(vote)
+// @notice evaluate as a query, a submitted transaction would put the opening on the ledger
+// args[0] : election type
+// args[1] : leaf index [from the vote receipt]
+// args[2] : leaf hash [from the vote receipt]
+// args[3] : ballot opening [optional json, Candidate and Salt for commit-reveal, Randomness for homomorphic]
+func (s *VotingChaincode) verifyMyVote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error(msg.GetErrMsg("COM_ERR_01", []string{"verifyMyVote", "3 or 4"}))
+	}
 
-	return shim.Success(voteJSON)
+	electionType := args[0]
+	opening := ""
+
+	if len(args) == 4 {
+		opening = args[3]
+	}
+
+	_, _, electionInfo, err := s.findElection(stub, electionType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	verification, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"verifyBallot", electionType, args[1], args[2], electionInfo.PublicKey, opening})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	return shim.Success(verification)
 }
 
 func (s *VotingChaincode) callOtherCC(stub shim.ChaincodeStubInterface, ccName string, channelID string, args []string) ([]byte, error) {
@@ -497,21 +1044,42 @@ func (s *VotingChaincode) getUserVotingHistory(stub shim.ChaincodeStubInterface,
 		return shim.Error(msg.GetErrMsg("COM_ERR_14", []string{ssn}))
 	}
 
-	historyIterator, err := stub.GetHistoryForKey(userPubKey)
-	if err != nil {
-		return shim.Error(msg.GetErrMsg("COM_ERR_19", []string{ssn, err.Error()}))
+	// follow rotated keys back to the first account of the user
+	accounts := []string{userPubKey}
+	visited := map[string]bool{userPubKey: true}
+	for account := userPubKey; ; {
+		var user User
+
+		userAsBytes, _ := stub.GetState(account)
+		json.Unmarshal(userAsBytes, &user)
+		if user.PreviousKey == "" || visited[user.PreviousKey] {
+			break
+		}
+
+		account = user.PreviousKey
+		visited[account] = true
+		accounts = append([]string{account}, accounts...)
 	}
 
 	history := make([]User, 0)
-	for historyIterator.HasNext() {
-		record, err := historyIterator.Next()
+	for _, account := range accounts {
+		historyIterator, err := stub.GetHistoryForKey(account)
 		if err != nil {
-			return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+			return shim.Error(msg.GetErrMsg("COM_ERR_19", []string{ssn, err.Error()}))
 		}
-		var user User
 
-		json.Unmarshal(record.Value, &user)
-		history = append(history, user)
+		for historyIterator.HasNext() {
+			record, err := historyIterator.Next()
+			if err != nil {
+				historyIterator.Close()
+				return shim.Error(msg.GetErrMsg("COM_ERR_13", []string{err.Error()}))
+			}
+			var user User
+
+			json.Unmarshal(record.Value, &user)
+			history = append(history, user)
+		}
+		historyIterator.Close()
 	}
 
 	historyAsBytes, _ := json.Marshal(&history)
@@ -590,19 +1158,56 @@ func (s *VotingChaincode) countVotes(stub shim.ChaincodeStubInterface, args []st
 		return shim.Error(msg.GetErrMsg("COM_ERR_07", []string{election}))
 	}
 
-	electionIsNotOver := u.IsWithinRange(todayDate, keyParts[1], keyParts[2], "2006/01/02")
-	if !electionIsNotOver {
+	isElectionOver := u.IsAfter(todayDate, keyParts[2], "2006/01/02")
+	if !isElectionOver {
 		return shim.Error(msg.GetErrMsg("VOT_ERR_17", []string{electionType, fmt.Sprint(keyParts[1] + "-" + keyParts[2]), todayDate}))
 	}
 
+	electionInfo, err := s.getElection(stub, election)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// @notice the result is frozen once counted
+	if electionInfo.ElectionResult != "" {
+		return shim.Error(msg.GetErrMsg("VOT_ERR_56", []string{electionType}))
+	}
+
+	if electionInfo.BallotMode == c.COMMIT_REVEAL {
+		revealDeadline := getRevealDeadline(keyParts[2])
+		if !u.IsAfter(todayDate, revealDeadline, "2006/01/02") {
+			return shim.Error(msg.GetErrMsg("VOT_ERR_55", []string{electionType, revealDeadline, "Not Over"}))
+		}
+	}
+
+	_, err = s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"closeBallotLog", electionType})
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
+	}
+
+	// @notice commit-reveal elections only count ballots revealed in the reveal period
 	votingRes, err := s.callOtherCC(stub, c.CCNAME, c.CHANNELID, []string{"getVotingResults", electionType})
 	if err != nil {
 		return shim.Error(msg.GetErrMsg("COM_ERR_17", []string{c.CCNAME, err.Error()}))
 	}
 
-	fmt.Println("VotingResult", votingRes)
+	electionInfo.ElectionResult = string(votingRes)
+	electionAsBytes, _ := json.Marshal(electionInfo)
 
-	return shim.Success(nil)
+	err = stub.PutState(election, electionAsBytes)
+	if err != nil {
+		return shim.Error(msg.GetErrMsg("COM_ERR_09", []string{election, err.Error()}))
+	}
+
+	// @notice elections registered before key-level endorsement have no endorsing organizations
+	if len(electionInfo.EndorsingOrgs) > 0 {
+		err = setEndorsement(stub, election, electionInfo.EndorsingOrgs)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(votingRes)
 }
 
 func main() {
@@ -1,57 +1,511 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"math/rand"
+	"encoding/pem"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
-	"./utils/elect_cc"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+
+	a "./utils/access"
+	c "./utils/constants"
+	"./utils/elect_cc"
+	u "./utils/keyUtils"
+	"./utils/merkle"
+	msg "./utils/msg"
 )
 
-func Init(test *testing.T) *shim.MockStub {
-	stub := shim.NewMockStub("VotingCCTestStub", new(VotingChaincode))
-	result := stub.MockInit("000", nil)
+const testMSP = "Org1MSP"
+
+// OID of the attribute extension Fabric CA adds to enrollment certificates
+var attrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// testStub is the MockStub with what it leaves out: the submitter
+// certificate, the transient map, paginated queries and the signed proposal
+// elect_cc checks.
+type testStub struct {
+	*shim.MockStub
+	test      *testing.T
+	cc        shim.Chaincode
+	creator   []byte
+	transient map[string][]byte
+	args      [][]byte
+	proposal  *pb.SignedProposal
+	peers     map[string]*testStub
+	tx        int
+	now       *timestamp.Timestamp
+}
+
+func newStub(test *testing.T, name string, cc shim.Chaincode) *testStub {
+	return &testStub{MockStub: shim.NewMockStub(name, cc), test: test, cc: cc, peers: map[string]*testStub{}}
+}
+
+// Returns voting_cc with elect_cc installed next to it, the submitter has no
+// certificate until as is called
+func newTestStub(test *testing.T) *testStub {
+	stub := newStub(test, c.VOTING_CCNAME, new(VotingChaincode))
+	stub.proposal = getProposal(c.VOTING_CCNAME)
+	stub.peers[c.CCNAME] = newStub(test, c.CCNAME, new(elect_cc.ElectChaincode))
 
-	if result.Status != shim.OK {
-		test.FailNow()
-	}
 	return stub
 }
 
-func Invoke(test *testing.T, stub *shim.MockStub, function string, args ...string) []byte {
-	ccArgs := make([][]byte, 1+len(args))
-	ccArgs[0] = []byte(function)
+func getProposal(chaincode string) *pb.SignedProposal {
+	extension, _ := proto.Marshal(&pb.ChaincodeHeaderExtension{ChaincodeId: &pb.ChaincodeID{Name: chaincode}})
+	channelHeader, _ := proto.Marshal(&common.ChannelHeader{Type: int32(common.HeaderType_ENDORSER_TRANSACTION), Extension: extension})
+	header, _ := proto.Marshal(&common.Header{ChannelHeader: channelHeader})
+	proposal, _ := proto.Marshal(&pb.Proposal{Header: header})
+
+	return &pb.SignedProposal{ProposalBytes: proposal}
+}
+
+// Returns a serialized identity with a self-signed certificate carrying the
+// attributes
+func getCreator(test *testing.T, mspID, name string, attrs map[string]string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	attrsAsBytes, _ := json.Marshal(map[string]interface{}{"attrs": attrs})
+
+	template := x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		Subject:         pkix.Name{CommonName: name, Organization: []string{mspID}},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: attrOID, Value: attrsAsBytes}}}
+
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	creator, _ := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certPEM})
+
+	return creator
+}
+
+// Submits the next transactions with a certificate of the organization
+// holding the role, attrs are extra attribute name and value pairs
+func (s *testStub) as(mspID, name, role string, attrs ...string) *testStub {
+	attributes := map[string]string{}
+	if role != "" {
+		attributes[c.ROLE_ATTRIBUTE] = role
+	}
+
+	for i := 0; i+1 < len(attrs); i += 2 {
+		attributes[attrs[i]] = attrs[i+1]
+	}
+
+	s.creator = getCreator(s.test, mspID, name, attributes)
+
+	return s
+}
+
+func (s *testStub) asRole(role string) *testStub {
+	return s.as(testMSP, role, role)
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+
+	return args[0], args[1:]
+}
+
+// Submits the next transactions with the client timestamp of the date,
+// peers run them at their own time
+func (s *testStub) at(date time.Time) *testStub {
+	s.now = &timestamp.Timestamp{Seconds: date.Unix(), Nanos: int32(date.Nanosecond())}
+
+	return s
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.now != nil {
+		return s.now, nil
+	}
+
+	return s.MockStub.GetTxTimestamp()
+}
+
+// Submits the next transactions with the transient map
+func (s *testStub) withTransient(transient map[string][]byte) *testStub {
+	s.transient = transient
+
+	return s
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return s.proposal, nil
+}
+
+// Chaincode to chaincode calls keep the client proposal, submitter and
+// transient map
+func (s *testStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	peer, found := s.peers[chaincodeName]
+	if !found {
+		return shim.Error("chaincode " + chaincodeName + " not installed")
+	}
+
+	peer.creator = s.creator
+	peer.proposal = s.proposal
+	peer.transient = s.transient
+
+	return peer.call(s.TxID, args)
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return getPage(iterator, pageSize, bookmark)
+}
+
+func (s *testStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := s.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return getPage(iterator, pageSize, bookmark)
+}
+
+// pageIterator iterates over one page of query results
+type pageIterator struct {
+	records []*queryresult.KV
+}
+
+func (i *pageIterator) HasNext() bool {
+	return len(i.records) > 0
+}
+
+func (i *pageIterator) Next() (*queryresult.KV, error) {
+	record := i.records[0]
+	i.records = i.records[1:]
+
+	return record, nil
+}
+
+func (i *pageIterator) Close() error {
+	return nil
+}
+
+// The bookmark is the first key of the page, like the peer bookmark of a range query
+func getPage(iterator shim.StateQueryIteratorInterface, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	defer iterator.Close()
+
+	page := &pageIterator{}
+	next := ""
+
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if record.Key < bookmark {
+			continue
+		}
+
+		if int32(len(page.records)) == pageSize {
+			next = record.Key
+			break
+		}
+
+		page.records = append(page.records, record)
+	}
+
+	return page, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.records)), Bookmark: next}, nil
+}
+
+func (s *testStub) call(txID string, args [][]byte) pb.Response {
+	s.args = args
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+
+	return s.cc.Invoke(s)
+}
+
+func (s *testStub) invoke(function string, args ...string) pb.Response {
+	s.tx++
+
+	return s.call(strconv.Itoa(s.tx), u.ArrayToChaincodeArgs(append([]string{function}, args...)))
+}
+
+// Invokes the function and fails the test unless it succeeds
+func (s *testStub) mustInvoke(function string, args ...string) []byte {
+	s.test.Helper()
+
+	response := s.invoke(function, args...)
+	if response.Status != shim.OK {
+		s.test.Fatalf("%s(%s) : %s", function, strings.Join(args, ", "), response.Message)
+	}
+
+	return response.Payload
+}
+
+// Invokes the function and fails the test unless it fails with the error code
+func (s *testStub) expectError(code, function string, args ...string) {
+	s.test.Helper()
+
+	response := s.invoke(function, args...)
+	if response.Status == shim.OK {
+		s.test.Fatalf("%s(%s) succeeded, expected %s", function, strings.Join(args, ", "), code)
+	}
+
+	// @notice the message up to its first parameter identifies the code
+	prefix := strings.Split(msg.GetErrMsg(code, []string{}), "%!")[0]
+	if !strings.Contains(response.Message, prefix) {
+		s.test.Fatalf("%s(%s) : %s, expected %s", function, strings.Join(args, ", "), response.Message, code)
+	}
+}
+
+func (s *testStub) unmarshal(payload []byte, val interface{}) {
+	s.test.Helper()
+
+	err := json.Unmarshal(payload, val)
+	if err != nil {
+		s.test.Fatalf("%s : %s", err, string(payload))
+	}
+}
+
+// Writes the state directly, for setting up what the chaincode cannot
+func (s *testStub) putState(key string, value []byte) {
+	s.MockTransactionStart("setup")
+	s.PutState(key, value)
+	s.MockTransactionEnd("setup")
+}
+
+func getDate(days int) string {
+	return time.Now().UTC().AddDate(0, 0, days).Format("2006/01/02")
+}
+
+// Elections are registered for next spring, the date checks of
+// registerElection do not cover periods across the end of a month
+func getStartDate() string {
+	return strconv.Itoa(time.Now().UTC().Year()+1) + "/03/10"
+}
+
+func getEndDate() string {
+	return strconv.Itoa(time.Now().UTC().Year()+1) + "/03/20"
+}
+
+// Moves the registered election of the type to the period, the chaincode
+// reads the election dates from the election composite key
+func (s *testStub) setElectionPeriod(electionType, startDate, endDate string) {
+	s.test.Helper()
+
+	s.MockTransactionStart("period")
+	defer s.MockTransactionEnd("period")
+
+	election, err := u.FindCompositeKey(s, c.ELECTION, []string{electionType})
+	if err != nil || election == "" {
+		s.test.Fatalf("election %s not found", electionType)
+	}
+
+	_, keyParts, _ := s.SplitCompositeKey(election)
+	electionAsBytes := s.State[election]
+
+	s.DelState(election)
+
+	moved, _ := s.CreateCompositeKey(c.ELECTION, []string{electionType, startDate, endDate, keyParts[3]})
+	s.PutState(moved, electionAsBytes)
+}
+
+func (s *testStub) getElectionRecord(electionType string) Election {
+	s.test.Helper()
+
+	election, _ := u.FindCompositeKey(s, c.ELECTION, []string{electionType})
+	electionInfo := Election{}
+	s.unmarshal(s.State[election], &electionInfo)
+
+	return electionInfo
+}
+
+// testUser is a registered user and the keys the test signs with
+type testUser struct {
+	SSN        string
+	Account    string
+	PrivateKey string
+}
+
+// Generates P-256 keys from the scheme registry
+func generateKeys(test *testing.T) *a.Keys {
+	test.Helper()
+
+	scheme, _ := a.GetScheme(a.P256)
+
+	keys, err := scheme.GenerateKeys()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	return keys
+}
+
+// Signs the call with the P-256 key of the user and returns its args
+// followed by R, S, X, Y
+func (user testUser) sign(test *testing.T, function string, args ...string) []string {
+	test.Helper()
+
+	scheme, _ := a.GetScheme(a.P256)
 
-	for i, arg := range args {
-		ccArgs[i+1] = []byte(arg)
+	signature, err := scheme.Sign(user.PrivateKey, a.GetSignedPayload(function, args))
+	if err != nil {
+		test.Fatal(err)
 	}
 
-	txID := rand.Int()
-	result := stub.MockInvoke(strconv.Itoa(txID), ccArgs)
+	d, _ := new(big.Int).SetString(user.PrivateKey, 16)
+	x, y := elliptic.P256().ScalarBaseMult(d.Bytes())
+
+	return append(args, signature.R, signature.S, x.String(), y.String())
+}
+
+// Registers the user as a registrar, with a P-256 key generated on-chain
+func (s *testStub) registerUser(ssn, dateOfBirth string) testUser {
+	s.test.Helper()
+
+	newUser := NewUser{}
+	s.unmarshal(s.asRole(c.REGISTRAR).mustInvoke("registerUser", ssn, "First"+ssn, "Last"+ssn, dateOfBirth, "M"), &newUser)
 
-	fmt.Println("Call: 		 ", function, "(", strings.Join(args, ","), ")")
-	fmt.Println("ResStatus:  ", result.Status)
-	fmt.Println("ResMsg 	 ", result.Message)
-	fmt.Println("ResPayload: ", string(result.Payload))
-	fmt.Println()
+	return testUser{ssn, newUser.PublicKey, newUser.PrivateKey}
+}
 
-	if result.Status != shim.OK {
-		test.FailNow()
+func getEvidence(documents ...string) string {
+	hashes := make([]string, 0)
+	for _, document := range documents {
+		hash := sha256.Sum256([]byte(document))
+		hashes = append(hashes, hex.EncodeToString(hash[:]))
 	}
-	return result.Payload
+
+	evidenceAsBytes, _ := json.Marshal(hashes)
+
+	return string(evidenceAsBytes)
+}
+
+// Has the registrar verify the identity of the user
+func (s *testStub) verify(registrar, user testUser) {
+	s.test.Helper()
+
+	evidence := getEvidence("passport " + user.SSN)
+	s.asRole(c.REGISTRAR).mustInvoke("verifyUser", registrar.sign(s.test, "verifyUser", user.SSN, evidence, registrar.Account)...)
+}
+
+// Registers and verifies a user born in 1980
+func (s *testStub) newUser(registrar testUser, ssn string) testUser {
+	s.test.Helper()
+
+	user := s.registerUser(ssn, "1980/01/01")
+	s.verify(registrar, user)
+
+	return user
+}
+
+// Registers a registrar with a key of its own to sign verifications,
+// enrolled by the registrar identity
+func (s *testStub) newRegistrar(ssn string) testUser {
+	s.test.Helper()
+
+	registrar := s.registerUser(ssn, "1970/01/01")
+	s.asRole(c.REGISTRAR).mustInvoke("enrollRegistrarKey", registrar.sign(s.test, "enrollRegistrarKey", registrar.Account)...)
+
+	return registrar
+}
+
+// Registers an election of the type for next spring
+func (s *testStub) registerElection(electionType string, args ...string) {
+	s.test.Helper()
+
+	s.asRole(c.OFFICIAL).mustInvoke("registerElection", append([]string{electionType, electionType + "2027", getStartDate(), getEndDate()}, args...)...)
+}
+
+func (s *testStub) registerCandidate(electionType string, candidate testUser) {
+	s.test.Helper()
+
+	s.asRole(c.VOTER).mustInvoke("registerCandidate", candidate.sign(s.test, "registerCandidate", electionType, candidate.Account)...)
+}
+
+func (s *testStub) registerVoter(electionType string, voter testUser) {
+	s.test.Helper()
+
+	s.asRole(c.REGISTRAR).mustInvoke("registerVoter", voter.SSN, electionType)
+}
+
+func (s *testStub) getUser(ssn string) User {
+	s.test.Helper()
+
+	user := User{}
+	s.unmarshal(s.asRole(c.AUDITOR).mustInvoke("getUser", c.IDENTITY, ssn), &user)
+
+	return user
 }
 
 func TestInvokeElectCC(test *testing.T) {
+	stub := newTestStub(test)
+	electStub := stub.peers[c.CCNAME]
+
+	// @notice elect_cc only accepts proposals sent to voting_cc
+	electStub.proposal = getProposal(c.CCNAME)
 
-	stub := Init(test)
-	stub.Invokables["elect_cc"] = shim.NewMockStub("elect_cc", new(elect_cc.ElectChaincode))
+	response := electStub.call("1", u.ArrayToChaincodeArgs([]string{"giveVote", "a", "b", "c", "d"}))
+	if response.Status == shim.OK {
+		test.Fatal("direct giveVote accepted")
+	}
 
-	Invoke(test, stub.Invokables["elect_cc"], "giveVote", "a", "b", "c", "d")
+	electStub.proposal = nil
 
+	response = electStub.call("2", u.ArrayToChaincodeArgs([]string{"giveVote", "a", "b", "c", "d"}))
+	if response.Status == shim.OK {
+		test.Fatal("giveVote without a signed proposal accepted")
+	}
 }
 
 /*
@@ -61,64 +515,1380 @@ Function List :
 3	| registerCandidate
 4	| registerVoter
 5	| getUser
+6	| vote
+7	| countVotes
 */
 
 func TestCCFunctions(test *testing.T) {
-	stub := Init(test)
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.newUser(registrar, "SSN_1")
+
+	stub.mustInvoke("getUser", c.USERKEY, candidate.Account)
+
+	if user := stub.getUser(voter.SSN); user.PublicKey != voter.Account {
+		test.Fatalf("unexpected user %+v", user)
+	}
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_09", "registerCandidate", candidate.sign(test, "registerCandidate", c.PRIMARY, candidate.Account)...)
+
+	stub.registerVoter(c.PRIMARY, voter)
+	stub.registerVoter(c.PRIMARY, candidate)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_10", "registerVoter", voter.SSN, c.PRIMARY)
+
+	// @notice voting opens today
+	stub.asRole(c.VOTER).expectError("VOT_ERR_13", "vote", voter.SSN, c.PRIMARY, candidate.Account)
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_12", "vote", candidate.SSN, c.PRIMARY, candidate.Account)
+
+	vote := Vote{}
+	stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, candidate.Account), &vote)
+	if vote.Candidate != candidate.Account || vote.LeafIndex != 0 || vote.LeafHash == "" {
+		test.Fatalf("unexpected vote %+v", vote)
+	}
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_14", "vote", voter.SSN, c.PRIMARY, candidate.Account)
+
+	if user := stub.getUser(voter.SSN); !strings.HasPrefix(user.Election, c.VOTED) {
+		test.Fatalf("voter not marked as voted : %s", user.Election)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 1 || result.Votes[candidate.Account] != 1 || result.BallotCount != 1 || result.BallotRoot == "" {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestRoles(test *testing.T) {
+	stub := newTestStub(test)
+
+	args := []string{c.PRIMARY, "primary2027", getStartDate(), getEndDate()}
+
+	// @notice no certificate attribute, no role
+	stub.as(testMSP, "nobody", "").expectError("ACC_ERR_01", "registerElection", args...)
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "registerElection", args...)
+	stub.asRole(c.REGISTRAR).expectError("ACC_ERR_01", "registerElection", args...)
+	stub.asRole(c.AUDITOR).expectError("ACC_ERR_01", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_01", "registerUser", "SSN_V", "First", "Last", "1980/01/01", "M")
 
-	var test_users []NewUser
-	user := NewUser{}
+	// @notice admins pass every route
+	stub.asRole(c.ADMIN).mustInvoke("registerElection", args...)
+	stub.asRole(c.ADMIN).mustInvoke("registerUser", "SSN_V", "First", "Last", "1980/01/01", "M")
 
-	userKeys := make([]string, 0)
-	userSSNs := make([]string, 0)
+	stub.asRole(c.VOTER).expectError("COM_ERR_11", "unknownFunction")
 
-	fmt.Println("= Register User =")
+	// @notice voters only register themselves, bound to their own identity
+	stub.asRole(c.VOTER).expectError("VOT_ERR_29", "registerUser", "SSN_W", "First", "Last", "1980/01/01", "M")
+	stub.asRole(c.VOTER).mustInvoke("registerUser", "SSN_W", "First", "Last", "1980/01/01", "M", "", "true")
+}
+
+func TestSignatureReplay(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerElection(c.GENERAL)
+
+	// @notice a signature only holds for the arguments it was made for
+	signed := candidate.sign(test, "registerCandidate", c.PRIMARY, candidate.Account)
+	stub.asRole(c.VOTER).expectError("COM_ERR_22", "registerCandidate", append([]string{c.GENERAL}, signed[1:]...)...)
+	stub.asRole(c.VOTER).mustInvoke("registerCandidate", signed...)
+
+	// @notice and for the function it was made for
+	keys := generateKeys(test)
+	signed = candidate.sign(test, "revokeKey", candidate.Account, keys.PublicKey)
+	stub.asRole(c.VOTER).expectError("COM_ERR_22", "rotateKey", signed...)
+	stub.asRole(c.VOTER).mustInvoke("rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+}
+
+func TestAgeAttestation(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	other := stub.registerUser("SSN_O", "1970/01/01")
+	voter := stub.registerUser("SSN_1", "")
+	stub.verify(registrar, voter)
+
+	stub.registerElection(c.PRIMARY)
+
+	// @notice no date of birth and no attestation, the voter is not registered
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_52", "registerVoter", voter.SSN, c.PRIMARY)
+
+	attest := func(registrar testUser) []string {
+		return registrar.sign(test, "attestAge", voter.SSN, "18", getStartDate(), registrar.Account)
+	}
+
+	// @notice only enrolled keys, used by the registrar that enrolled them
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_53", "attestAge", attest(other)...)
+	stub.as(testMSP, "registrar2", c.REGISTRAR).expectError("ACC_ERR_07", "attestAge", attest(registrar)...)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_54", "enrollRegistrarKey", registrar.sign(test, "enrollRegistrarKey", registrar.Account)...)
+
+	attestation := AgeAttestation{}
+	stub.unmarshal(stub.at(time.Date(2026, 4, 1, 10, 30, 0, 0, time.UTC)).asRole(c.REGISTRAR).mustInvoke("attestAge", attest(registrar)...), &attestation)
+	if attestation.Registrar != registrar.Account || attestation.MSPID != testMSP || attestation.Subject == "" || attestation.AttestationDate != "2026/04/01 10:30:00" {
+		test.Fatalf("unexpected attestation %+v", attestation)
+	}
+
+	newVoter := NewVoter{}
+	stub.unmarshal(stub.asRole(c.REGISTRAR).mustInvoke("registerVoter", voter.SSN, c.PRIMARY), &newVoter)
+	if newVoter.Age != "18+" || !newVoter.Eligibility {
+		test.Fatalf("unexpected voter %+v", newVoter)
+	}
+
+	// @notice removed keys are no longer accepted
+	stub.asRole(c.REGISTRAR).expectError("ACC_ERR_01", "removeRegistrarKey", registrar.Account)
+	stub.asRole(c.ADMIN).mustInvoke("removeRegistrarKey", registrar.Account)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_53", "attestAge", attest(registrar)...)
+}
+
+func TestVerification(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	other := stub.registerUser("SSN_O", "1970/01/01")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.registerUser("SSN_1", "1980/01/01")
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_42", "registerVoter", voter.SSN, c.PRIMARY)
+
+	evidence := getEvidence("passport " + voter.SSN)
+	verify := func(registrar testUser) []string {
+		return registrar.sign(test, "verifyUser", voter.SSN, evidence, registrar.Account)
+	}
+
+	// @notice only enrolled keys, used by the registrar that enrolled them
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_53", "verifyUser", verify(other)...)
+	stub.as(testMSP, "registrar2", c.REGISTRAR).expectError("ACC_ERR_07", "verifyUser", verify(registrar)...)
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "verifyUser", verify(registrar)...)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_41", "verifyUser", registrar.sign(test, "verifyUser", voter.SSN, "[\"passport\"]", registrar.Account)...)
+
+	verification := Verification{}
+	stub.unmarshal(stub.at(time.Date(2026, 5, 2, 9, 15, 0, 0, time.UTC)).asRole(c.REGISTRAR).mustInvoke("verifyUser", verify(registrar)...), &verification)
+	if verification.Status != c.VERIFIED || verification.Registrar != registrar.Account || verification.MSPID != testMSP || verification.Subject == "" || len(verification.Evidence) != 1 || verification.VerificationDate != "2026/05/02 09:15:00" {
+		test.Fatalf("unexpected verification %+v", verification)
+	}
+
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_43", "verifyUser", verify(registrar)...)
+	stub.registerVoter(c.PRIMARY, voter)
+
+	// @notice revoked users can no longer vote until verified again
+	reason := "forged passport"
+	stub.asRole(c.REGISTRAR).mustInvoke("revokeVerification", registrar.sign(test, "revokeVerification", voter.SSN, reason, registrar.Account)...)
+	if user := stub.getUser(voter.SSN); user.Verification != c.VERIFICATION_REVOKED {
+		test.Fatalf("unexpected user %+v", user)
+	}
 
-	for i := 0; i < 2; i++ {
-		ssn := fmt.Sprint("SSN_" + strconv.Itoa(rand.Int()))
-		firstName := fmt.Sprint("FirstName" + strconv.Itoa(rand.Int()))
-		lastName := fmt.Sprint("LastName" + strconv.Itoa(rand.Int()))
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+	stub.asRole(c.VOTER).expectError("VOT_ERR_42", "vote", voter.SSN, c.PRIMARY, candidate.Account)
 
-		res := Invoke(test, stub, "registerUser", ssn, firstName, lastName, "1992/02/24", "M")
-		json.Unmarshal(res, &user)
-		test_users = append(test_users, user)
+	evidence = getEvidence("id card " + voter.SSN)
+	stub.asRole(c.REGISTRAR).mustInvoke("verifyUser", verify(registrar)...)
+	stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, candidate.Account)
+}
+
+func TestCommitReveal(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.newUser(registrar, "SSN_1")
+	copier := stub.newUser(registrar, "SSN_2")
+
+	stub.registerElection(c.GENERAL, c.COMMIT_REVEAL)
+	stub.registerCandidate(c.GENERAL, candidate)
+	stub.registerVoter(c.GENERAL, voter)
+	stub.registerVoter(c.GENERAL, copier)
+	stub.setElectionPeriod(c.GENERAL, getDate(0), getDate(1))
+
+	// @notice the commitment is bound to the election and the voter
+	salt := "5a17"
+	commitment := a.GetCommitment(c.GENERAL+c.SEPARATOR+c.GENERAL+"2027", voter.SSN, candidate.Account, salt)
+	vote := Vote{}
+	stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.GENERAL, commitment), &vote)
+	stub.asRole(c.VOTER).mustInvoke("vote", copier.SSN, c.GENERAL, commitment)
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_17", "revealVote", voter.SSN, c.GENERAL, candidate.Account, salt)
+	stub.setElectionPeriod(c.GENERAL, getDate(-2), getDate(-1))
+
+	stub.asRole(c.VOTER).expectError("ELECT_ERR_04", "revealVote", copier.SSN, c.GENERAL, candidate.Account, salt)
+	stub.asRole(c.VOTER).expectError("ELECT_ERR_04", "revealVote", voter.SSN, c.GENERAL, candidate.Account, "other salt")
+
+	// @notice the revealed ballot is not keyed by anything stored next to the ssn
+	ballot := elect_cc.Ballot{}
+	stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("revealVote", voter.SSN, c.GENERAL, candidate.Account, salt), &ballot)
+	if ballot.BallotID == commitment || ballot.BallotID != a.GetBallotID(c.GENERAL+c.SEPARATOR+c.GENERAL+"2027", salt) || ballot.Candidate != candidate.Account {
+		test.Fatalf("unexpected ballot %+v", ballot)
+	}
+
+	for key := range stub.peers[c.CCNAME].State {
+		if strings.Contains(key, voter.SSN) && !strings.HasPrefix(key, "\x00"+c.VOTING_COMMIT) {
+			test.Fatalf("reveal of %s kept under %q", voter.SSN, key)
+		}
+	}
+
+	stub.asRole(c.VOTER).expectError("ELECT_ERR_03", "revealVote", voter.SSN, c.GENERAL, candidate.Account, salt)
+
+	// @notice votes are counted once the reveal period is over, and only once
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_55", "countVotes", c.PLURALITY, c.GENERAL)
+	stub.setElectionPeriod(c.GENERAL, getDate(-3-c.REVEAL_DAYS), getDate(-1-c.REVEAL_DAYS))
+	stub.asRole(c.VOTER).expectError("VOT_ERR_55", "revealVote", copier.SSN, c.GENERAL, candidate.Account, salt)
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.GENERAL), &result)
+	if result.Total != 1 || result.Votes[candidate.Account] != 1 || result.BallotCount != 2 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_56", "countVotes", c.PLURALITY, c.GENERAL)
 
-		userSSNs = append(userSSNs, user.SSN)
-		userKeys = append(userKeys, user.PublicKey)
+	// @notice the opening finds the revealed ballot without the state linking the two
+	opening, _ := json.Marshal(elect_cc.BallotOpening{Candidate: candidate.Account, Salt: salt})
+	verification := elect_cc.VoteVerification{}
+	stub.unmarshal(stub.mustInvoke("verifyMyVote", c.GENERAL, strconv.Itoa(vote.LeafIndex), vote.LeafHash, string(opening)), &verification)
+	if !verification.Counted || verification.CountedAs != candidate.Account {
+		test.Fatalf("unexpected verification %+v", verification)
+	}
+
+	opening, _ = json.Marshal(elect_cc.BallotOpening{Candidate: candidate.Account, Salt: "other salt"})
+	stub.unmarshal(stub.mustInvoke("verifyMyVote", c.GENERAL, strconv.Itoa(vote.LeafIndex), vote.LeafHash, string(opening)), &verification)
+	if verification.Counted {
+		test.Fatalf("unexpected verification %+v", verification)
+	}
+}
+
+func TestKeyRotation(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.newUser(registrar, "SSN_1")
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	stub.registerVoter(c.PRIMARY, voter)
+
+	keys := generateKeys(test)
+	rotated := testUser{candidate.SSN, a.GenerateAccount(keys.PublicKey), keys.PrivateKey}
 
+	// @notice the holder of the current key rotates it, or an official approves the new key
+	stub.asRole(c.AUDITOR).expectError("ACC_ERR_01", "rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_29", "rotateKey", candidate.Account, keys.PublicKey)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_29", "rotateKey", candidate.Account, keys.PublicKey)
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_01", "rotateKey", candidate.Account, keys.PublicKey, "R", "S")
+	stub.asRole(c.VOTER).expectError("COM_ERR_22", "rotateKey", voter.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+
+	user := User{}
+	stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...), &user)
+	if user.PublicKey != rotated.Account || user.PreviousKey != candidate.Account {
+		test.Fatalf("unexpected user %+v", user)
 	}
 
-	fmt.Println("= User SSN = ")
-	fmt.Println("User 0 SSN ", userSSNs[0])
-	fmt.Println("User 1 SSN ", userSSNs[1])
+	stub.asRole(c.VOTER).expectError("COM_ERR_23", "rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+
+	// @notice votes for the retired account are rejected rather than split from the tally
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+	stub.asRole(c.VOTER).expectError("VOT_ERR_28", "vote", voter.SSN, c.PRIMARY, candidate.Account)
+	stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, rotated.Account)
 
-	fmt.Println("= Get User By Key =")
+	// @notice officials of a jurisdiction only approve keys of its residents
+	lost := stub.newUser(registrar, "SSN_2")
+	stub.asRole(c.REGISTRAR).mustInvoke("setResidence", lost.SSN, "springfield")
+
+	keys = generateKeys(test)
+	asOfficial := func(jurisdiction string) *testStub {
+		return stub.as(testMSP, "official", c.OFFICIAL, c.JURISDICTION_ATTRIBUTE, jurisdiction)
+	}
 
-	for i := 0; i < len(userSSNs); i++ {
-		Invoke(test, stub, "getUser", "USERKEY", userKeys[i])
+	asOfficial("shelbyville").expectError("ACC_ERR_12", "rotateKey", lost.Account, keys.PublicKey)
+	stub.unmarshal(asOfficial("springfield").mustInvoke("rotateKey", lost.Account, keys.PublicKey), &user)
+	if user.PublicKey != a.GenerateAccount(keys.PublicKey) || user.PreviousKey != lost.Account {
+		test.Fatalf("unexpected user %+v", user)
 	}
 
-	fmt.Println("= Get User By ID =")
-	Invoke(test, stub, "getUser", "identity", userSSNs[0])
+	keys = generateKeys(test)
+	stub.asRole(c.OFFICIAL).mustInvoke("rotateKey", user.PublicKey, keys.PublicKey, a.P256)
+}
 
-	fmt.Println("= Register Election =")
-	Invoke(test, stub, "registerElection", "primary", "ElectionID", "2019/03/12", "2019/03/20")
+func TestKeyRecovery(test *testing.T) {
+	stub := newTestStub(test)
 
-	fmt.Println("= Register Candidate =")
-	Invoke(test, stub, "registerCandidate", "primary", userSSNs[0], userKeys[0])
+	registrar := stub.newRegistrar("SSN_R")
+	voter := stub.newUser(registrar, "SSN_1")
 
-	fmt.Println("= Register Voter =")
-	Invoke(test, stub, "registerVoter", userSSNs[1], "primary")
+	keys := generateKeys(test)
 
-	fmt.Println("= Register Voter Candidate Case =")
-	Invoke(test, stub, "registerVoter", userSSNs[0], "primary")
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "recoverKey", voter.SSN, keys.PublicKey)
+	stub.asRole(c.REGISTRAR).expectError("COM_ERR_18", "recoverKey", voter.SSN, "not a key")
 
-	fmt.Println("= Vote =")
-	Invoke(test, stub, "vote", userSSNs[1], "primary", userKeys[1])
+	// @notice the key pair is generated by the user, only the public key is submitted
+	user := User{}
+	stub.unmarshal(stub.asRole(c.REGISTRAR).mustInvoke("recoverKey", voter.SSN, keys.PublicKey), &user)
+	if user.PublicKey != a.GenerateAccount(keys.PublicKey) || user.PreviousKey != voter.Account || strings.Contains(string(stub.State[user.PublicKey]), keys.PrivateKey) {
+		test.Fatalf("unexpected user %+v", user)
+	}
 
-	fmt.Println("= Get User Info After Election =")
-	Invoke(test, stub, "getUser", "identity", userSSNs[1])
+	stub.asRole(c.VOTER).expectError("COM_ERR_23", "revokeKey", voter.sign(test, "revokeKey", voter.Account, "lost")...)
 
-	// @notice unit test for key history not implemented
+	// @notice admins pass the registrar checks, the revocation is dated by the transaction
+	recovered := testUser{voter.SSN, user.PublicKey, keys.PrivateKey}
+	revocation := Revocation{}
+	stub.unmarshal(stub.at(time.Date(2026, 4, 1, 10, 30, 0, 0, time.UTC)).asRole(c.ADMIN).mustInvoke("revokeKey", recovered.Account, "stolen"), &revocation)
+	if revocation.RevocationDate != "2026/04/01 10:30:00" {
+		test.Fatalf("unexpected revocation %+v", revocation)
+	}
+	stub.asRole(c.VOTER).expectError("VOT_ERR_29", "revokeKey", recovered.Account, "stolen")
 
+	keys = generateKeys(test)
+	stub.asRole(c.ADMIN).mustInvoke("recoverKey", voter.SSN, keys.PublicKey)
+	if user := stub.getUser(voter.SSN); user.PublicKey != a.GenerateAccount(keys.PublicKey) {
+		test.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestBlindToken(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.newUser(registrar, "SSN_1")
+
+	authority, _ := rsa.GenerateKey(rand.Reader, 2048)
+	authorityKey, _ := a.EncodeRSAPublicKey(&authority.PublicKey)
+
+	shortKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	encoded, _ := a.EncodeRSAPublicKey(&shortKey.PublicKey)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_21", "registerElection", c.PRIMARY, "primary2027", getStartDate(), getEndDate(), c.BLIND_TOKEN, encoded)
+
+	stub.registerElection(c.PRIMARY, c.BLIND_TOKEN, authorityKey)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	stub.registerVoter(c.PRIMARY, voter)
+
+	// @notice the authority signs the blinded token off-chain
+	token := "ballot token of " + voter.SSN
+	blinded, blindingFactor, _ := a.BlindToken(&authority.PublicKey, token)
+	blindSignature := a.SignBlindedToken(authority, blinded)
+
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_22", "issueBallotToken", voter.SSN, c.PRIMARY, blinded, a.SignBlindedToken(shortKey, blinded))
+	stub.asRole(c.OFFICIAL).mustInvoke("issueBallotToken", voter.SSN, c.PRIMARY, blinded, blindSignature)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_20", "issueBallotToken", voter.SSN, c.PRIMARY, blinded, blindSignature)
+
+	signature, _ := a.UnblindSignature(&authority.PublicKey, blindSignature, blindingFactor)
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	// @notice any identity casts the ballot, the token is not linked to the issuance
+	stub.as(testMSP, "anonymous", "").expectError("COM_ERR_22", "castBallot", c.PRIMARY, "another token", signature, candidate.Account)
+	stub.as(testMSP, "anonymous", "").mustInvoke("castBallot", c.PRIMARY, token, signature, candidate.Account)
+	stub.as(testMSP, "anonymous", "").expectError("ELECT_ERR_05", "castBallot", c.PRIMARY, token, signature, candidate.Account)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 1 || result.Votes[candidate.Account] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+// Returns the encrypted tally elect_cc keeps for the election
+func (s *testStub) getEncryptedTally(electionType string) elect_cc.EncryptedTally {
+	s.test.Helper()
+
+	electStub := s.peers[c.CCNAME]
+	tallyKey, _ := electStub.CreateCompositeKey(c.ENCRYPTED_TALLY, []string{electionType})
+
+	tally := elect_cc.EncryptedTally{}
+	s.unmarshal(electStub.State[tallyKey], &tally)
+
+	return tally
+}
+
+// Decrypts every ciphertext of the tally with the key, off-chain
+func getDecryptionShares(test *testing.T, privKey string, tally elect_cc.EncryptedTally, context string) string {
+	test.Helper()
+
+	shares := make([]a.DecryptionShare, 0)
+	for _, ciphertext := range tally.Ciphertexts {
+		share, err := a.DecryptShare(privKey, ciphertext, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+		shares = append(shares, *share)
+	}
+
+	sharesAsBytes, _ := json.Marshal(shares)
+
+	return string(sharesAsBytes)
+}
+
+func TestHomomorphicBallot(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidates := []testUser{stub.newUser(registrar, "SSN_0"), stub.newUser(registrar, "SSN_1")}
+	voters := []testUser{stub.newUser(registrar, "SSN_2"), stub.newUser(registrar, "SSN_3"), stub.newUser(registrar, "SSN_4")}
+
+	privKey, pubKey, _ := a.GenerateElectionKeys()
+	otherKey, _, _ := a.GenerateElectionKeys()
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_21", "registerElection", c.PRIMARY, "primary2027", getStartDate(), getEndDate(), c.HOMOMORPHIC, "not a key")
+	stub.registerElection(c.PRIMARY, c.HOMOMORPHIC, pubKey)
+
+	for _, candidate := range candidates {
+		stub.registerCandidate(c.PRIMARY, candidate)
+	}
+
+	for _, voter := range voters {
+		stub.registerVoter(c.PRIMARY, voter)
+	}
+
+	// @notice voters encrypt a vote for the index of their candidate in the ballot order
+	ballotOrder := []string{}
+	stub.unmarshal(stub.mustInvoke("getBallotCandidates", c.PRIMARY), &ballotOrder)
+	if len(ballotOrder) != 2 {
+		test.Fatalf("unexpected ballot candidates %+v", ballotOrder)
+	}
+
+	context := getBallotContext(stub.getElectionRecord(c.PRIMARY))
+	encrypt := func(candidate testUser, context string) string {
+		test.Helper()
+
+		choice := 0
+		if ballotOrder[1] == candidate.Account {
+			choice = 1
+		}
+
+		ballot, _, err := a.EncryptBallot(pubKey, len(ballotOrder), choice, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		ballotAsBytes, _ := json.Marshal(ballot)
+
+		return string(ballotAsBytes)
+	}
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	stub.asRole(c.VOTER).expectError("ELECT_ERR_06", "vote", voters[0].SSN, c.PRIMARY, encrypt(candidates[0], c.GENERAL+c.SEPARATOR+"general2027"))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[0].SSN, c.PRIMARY, encrypt(candidates[0], context))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[1].SSN, c.PRIMARY, encrypt(candidates[0], context))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[2].SSN, c.PRIMARY, encrypt(candidates[1], context))
+
+	tally := stub.getEncryptedTally(c.PRIMARY)
+	if tally.Ballots != 3 || len(tally.Ciphertexts) != 2 || tally.Counts != nil {
+		test.Fatalf("unexpected tally %+v", tally)
+	}
+
+	shares := getDecryptionShares(test, privKey, tally, context)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "decryptTally", c.PRIMARY, shares)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	// @notice the result waits for the decrypted tally
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_18", "countVotes", c.PLURALITY, c.PRIMARY)
+
+	// @notice shares are checked against the election key, D alone proves nothing
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_08", "decryptTally", c.PRIMARY, getDecryptionShares(test, otherKey, tally, context))
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_08", "decryptTally", c.PRIMARY, getDecryptionShares(test, privKey, tally, c.GENERAL+c.SEPARATOR+"general2027"))
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "decryptTally", c.PRIMARY, shares)
+
+	decrypted := elect_cc.EncryptedTally{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("decryptTally", c.PRIMARY, shares), &decrypted)
+	if len(decrypted.Counts) != 2 {
+		test.Fatalf("unexpected tally %+v", decrypted)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_09", "decryptTally", c.PRIMARY, shares)
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 3 || result.Votes[candidates[0].Account] != 2 || result.Votes[candidates[1].Account] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestTrusteeDecryption(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidates := []testUser{stub.newUser(registrar, "SSN_0"), stub.newUser(registrar, "SSN_1")}
+	voters := []testUser{stub.newUser(registrar, "SSN_2"), stub.newUser(registrar, "SSN_3"), stub.newUser(registrar, "SSN_4")}
+	trustees := []testUser{stub.newUser(registrar, "SSN_T1"), stub.newUser(registrar, "SSN_T2"), stub.newUser(registrar, "SSN_T3")}
+	outsider := stub.newUser(registrar, "SSN_X")
+
+	// @notice without an election key the key is generated by the trustees
+	stub.registerElection(c.PRIMARY, c.HOMOMORPHIC)
+
+	accounts := make([]string, 0)
+	for _, trustee := range trustees {
+		accounts = append(accounts, trustee.Account)
+	}
+
+	accountsAsBytes, _ := json.Marshal(accounts)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_25", "registerTrustees", c.PRIMARY, "4", string(accountsAsBytes))
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_18", "registerTrustees", c.PRIMARY, "2", `["`+accounts[0]+`", "`+accounts[0]+`"]`)
+	stub.asRole(c.OFFICIAL).mustInvoke("registerTrustees", c.PRIMARY, "2", string(accountsAsBytes))
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_24", "registerTrustees", c.PRIMARY, "2", string(accountsAsBytes))
+
+	for _, candidate := range candidates {
+		stub.registerCandidate(c.PRIMARY, candidate)
+	}
+
+	for _, voter := range voters {
+		stub.registerVoter(c.PRIMARY, voter)
+	}
+
+	context := getBallotContext(stub.getElectionRecord(c.PRIMARY))
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	// @notice every trustee deals a share of its polynomial to the others off-chain
+	coefficients := make([][]string, len(trustees))
+	for i, trustee := range trustees {
+		polynomial, commitments, err := a.GeneratePolynomial(2, i+1, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+		coefficients[i] = polynomial
+
+		commitmentsAsBytes, _ := json.Marshal(commitments)
+		if i == 0 {
+			stub.asRole(c.OFFICIAL).expectError("VOT_ERR_26", "submitKeyCommitments", outsider.sign(test, "submitKeyCommitments", c.PRIMARY, outsider.Account, string(commitmentsAsBytes))...)
+			stub.asRole(c.OFFICIAL).expectError("VOT_ERR_21", "submitKeyCommitments", trustees[1].sign(test, "submitKeyCommitments", c.PRIMARY, trustees[1].Account, string(commitmentsAsBytes))...)
+		}
+
+		stub.asRole(c.OFFICIAL).mustInvoke("submitKeyCommitments", trustee.sign(test, "submitKeyCommitments", c.PRIMARY, trustee.Account, string(commitmentsAsBytes))...)
+		if i == 0 {
+			stub.asRole(c.OFFICIAL).expectError("VOT_ERR_27", "submitKeyCommitments", trustee.sign(test, "submitKeyCommitments", c.PRIMARY, trustee.Account, string(commitmentsAsBytes))...)
+			stub.asRole(c.VOTER).expectError("VOT_ERR_22", "vote", voters[0].SSN, c.PRIMARY, "{}")
+		}
+	}
+
+	pubKey := stub.getElectionRecord(c.PRIMARY).PublicKey
+	if pubKey == "" {
+		test.Fatal("election key not combined")
+	}
+
+	keyShares := make([]string, len(trustees))
+	for j := range trustees {
+		dealt := make([]string, 0)
+		for i := range trustees {
+			dealt = append(dealt, a.EvaluateShare(coefficients[i], j+1))
+		}
+		keyShares[j] = a.CombineKeyShares(dealt)
+	}
+
+	ballotOrder := []string{}
+	stub.unmarshal(stub.mustInvoke("getBallotCandidates", c.PRIMARY), &ballotOrder)
+
+	for i, voter := range voters {
+		ballot, _, err := a.EncryptBallot(pubKey, len(ballotOrder), i%2, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		ballotAsBytes, _ := json.Marshal(ballot)
+		stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, string(ballotAsBytes))
+	}
+
+	tally := stub.getEncryptedTally(c.PRIMARY)
+	partial := func(trustee int, keyShare string) []string {
+		shares := getDecryptionShares(test, keyShare, tally, context)
+		return trustees[trustee].sign(test, "submitPartialDecryption", c.PRIMARY, trustees[trustee].Account, shares)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "submitPartialDecryption", partial(0, keyShares[0])...)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	// @notice the election key has no private half to decrypt with
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_23", "decryptTally", c.PRIMARY, getDecryptionShares(test, keyShares[0], tally, context))
+
+	// @notice partial decryptions are checked against the verification key of the trustee
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_14", "submitPartialDecryption", partial(1, keyShares[2])...)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_26", "submitPartialDecryption", outsider.sign(test, "submitPartialDecryption", c.PRIMARY, outsider.Account, "[]")...)
+
+	stub.asRole(c.OFFICIAL).mustInvoke("submitPartialDecryption", partial(0, keyShares[0])...)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_27", "submitPartialDecryption", partial(0, keyShares[0])...)
+
+	// @notice fewer than threshold partial decryptions leave the tally encrypted
+	if tally = stub.getEncryptedTally(c.PRIMARY); tally.Counts != nil {
+		test.Fatalf("tally decrypted below the threshold %+v", tally)
+	}
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_18", "countVotes", c.PLURALITY, c.PRIMARY)
+
+	decrypted := elect_cc.EncryptedTally{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("submitPartialDecryption", partial(2, keyShares[2])...), &decrypted)
+	if len(decrypted.Counts) != 2 || decrypted.Counts[0] != 2 || decrypted.Counts[1] != 1 {
+		test.Fatalf("unexpected tally %+v", decrypted)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_09", "submitPartialDecryption", partial(1, keyShares[1])...)
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 3 || result.Votes[ballotOrder[0]] != 2 || result.Votes[ballotOrder[1]] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestDelegation(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	voter := stub.newUser(registrar, "SSN_1")
+	other := stub.newUser(registrar, "SSN_2")
+	unbound := stub.newUser(registrar, "SSN_3")
+
+	// @notice the proxy registers itself, bound to its own identity
+	newUser := NewUser{}
+	stub.unmarshal(stub.as(testMSP, "proxy", c.VOTER).mustInvoke("registerUser", "SSN_P", "FirstP", "LastP", "1980/01/01", "M", "", "true"), &newUser)
+	proxy := testUser{newUser.SSN, newUser.PublicKey, newUser.PrivateKey}
+	stub.verify(registrar, proxy)
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	for _, user := range []testUser{voter, other, unbound, proxy} {
+		stub.registerVoter(c.PRIMARY, user)
+	}
+
+	// @notice a proxy without a bound identity could be used by anyone
+	stub.asRole(c.VOTER).expectError("VOT_ERR_57", "delegateVote", voter.sign(test, "delegateVote", voter.Account, c.PRIMARY, unbound.SSN)...)
+	stub.asRole(c.VOTER).expectError("COM_ERR_22", "delegateVote", other.sign(test, "delegateVote", voter.Account, c.PRIMARY, proxy.SSN)...)
+
+	delegation := Delegation{}
+	stub.unmarshal(stub.at(time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)).asRole(c.VOTER).mustInvoke("delegateVote", voter.sign(test, "delegateVote", voter.Account, c.PRIMARY, proxy.SSN)...), &delegation)
+	if delegation.Status != c.ACTIVE || delegation.DelegatorSSN != voter.SSN || delegation.ProxySSN != proxy.SSN || delegation.DelegationDate != "2026/06/01 08:00:00" {
+		test.Fatalf("unexpected delegation %+v", delegation)
+	}
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_44", "delegateVote", voter.sign(test, "delegateVote", voter.Account, c.PRIMARY, proxy.SSN)...)
+	stub.asRole(c.VOTER).mustInvoke("delegateVote", other.sign(test, "delegateVote", other.Account, c.PRIMARY, proxy.SSN)...)
+
+	// @notice a revoked delegation is not renewed
+	stub.asRole(c.VOTER).mustInvoke("revokeDelegation", other.sign(test, "revokeDelegation", other.Account, c.PRIMARY)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_44", "revokeDelegation", other.sign(test, "revokeDelegation", other.Account, c.PRIMARY)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_44", "delegateVote", other.sign(test, "delegateVote", other.Account, c.PRIMARY, proxy.SSN)...)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	// @notice only the identity bound to the proxy votes for the delegator
+	stub.asRole(c.VOTER).expectError("ACC_ERR_07", "vote", voter.SSN, c.PRIMARY, candidate.Account, proxy.SSN)
+	stub.as(testMSP, "proxy", c.VOTER).expectError("VOT_ERR_44", "vote", other.SSN, c.PRIMARY, candidate.Account, proxy.SSN)
+
+	vote := Vote{}
+	stub.unmarshal(stub.as(testMSP, "proxy", c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, candidate.Account, proxy.SSN), &vote)
+	if vote.VoterSSN != voter.SSN || vote.Proxy != proxy.SSN {
+		test.Fatalf("unexpected vote %+v", vote)
+	}
+
+	// @notice the delegated vote is counted once
+	stub.asRole(c.VOTER).expectError("VOT_ERR_14", "vote", voter.SSN, c.PRIMARY, candidate.Account)
+	stub.as(testMSP, "proxy", c.VOTER).expectError("VOT_ERR_14", "vote", voter.SSN, c.PRIMARY, candidate.Account, proxy.SSN)
+
+	stub.as(testMSP, "proxy", c.VOTER).mustInvoke("vote", proxy.SSN, c.PRIMARY, candidate.Account)
+	stub.asRole(c.VOTER).mustInvoke("vote", other.SSN, c.PRIMARY, candidate.Account)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 3 || result.Votes[candidate.Account] != 3 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestAuditLog(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	voter := stub.newUser(registrar, "SSN_1")
+	stub.registerElection(c.PRIMARY)
+	timestamp := stub.TxTimestamp
+
+	// @notice neither voters nor queries are audited
+	stub.asRole(c.VOTER).mustInvoke("registerUser", "SSN_V", "First", "Last", "1980/01/01", "M", "", "true")
+	stub.asRole(c.REGISTRAR).mustInvoke("getUser", c.IDENTITY, voter.SSN)
+
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "getAuditLog", "", "10")
+
+	auditLog := AuditLog{}
+	stub.unmarshal(stub.asRole(c.AUDITOR).mustInvoke("getAuditLog", "", "10"), &auditLog)
+	if len(auditLog.Entries) != 5 {
+		test.Fatalf("unexpected audit log %+v", auditLog)
+	}
+
+	// @notice the entry is dated with the transaction timestamp, not the endorser clock
+	date := time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC()
+	for _, entry := range auditLog.Entries {
+		if entry.Function == "registerElection" && (entry.Role != c.OFFICIAL || entry.MSPID != testMSP || entry.Args[0] != c.PRIMARY || entry.Date != date.Format("2006/01/02 15:04:05")) {
+			test.Fatalf("unexpected entry %+v", entry)
+		}
+	}
+
+	stub.asRole(c.AUDITOR).mustInvoke("getAuditLog", "", "10", date.Format("2006/01/02"))
+
+	// @notice the audit log pages through the entries
+	stub.unmarshal(stub.asRole(c.AUDITOR).mustInvoke("getAuditLog", "", "3"), &auditLog)
+	if len(auditLog.Entries) != 3 || auditLog.Bookmark == "" {
+		test.Fatalf("unexpected audit log %+v", auditLog)
+	}
+
+	stub.unmarshal(stub.asRole(c.AUDITOR).mustInvoke("getAuditLog", auditLog.Bookmark, "3"), &auditLog)
+	if len(auditLog.Entries) != 2 || auditLog.Bookmark != "" {
+		test.Fatalf("unexpected audit log %+v", auditLog)
+	}
+
+	voterRoll := VoterRoll{}
+	stub.registerVoter(c.PRIMARY, voter)
+	stub.unmarshal(stub.asRole(c.AUDITOR).mustInvoke("getVoterRoll", c.PRIMARY, "", "100"), &voterRoll)
+	if len(voterRoll.Voters) != 1 || voterRoll.Voters[0].SSN != voter.SSN {
+		test.Fatalf("unexpected voter roll %+v", voterRoll)
+	}
+
+	// @notice read only functions cannot write state and only reach the queries of elect_cc
+	stub.asRole(c.AUDITOR).mustInvoke("getBallots", c.PRIMARY, "", "10")
+
+	stub.MockTransactionStart("query")
+	defer stub.MockTransactionEnd("query")
+
+	readOnly := u.ReadOnly(stub, "getBallots")
+	err := readOnly.PutState("key", []byte("value"))
+	if err == nil || !strings.Contains(err.Error(), "Refused to Write") {
+		test.Fatalf("state written from a read only function : %v", err)
+	}
+
+	response := readOnly.InvokeChaincode(c.CCNAME, u.ArrayToChaincodeArgs([]string{"closeBallotLog", c.PRIMARY}), c.CHANNELID)
+	if response.Status == shim.OK || !strings.Contains(response.Message, "Refused to Call \"closeBallotLog\"") {
+		test.Fatalf("closeBallotLog called from a read only function : %s", response.Message)
+	}
+
+	response = readOnly.InvokeChaincode(c.VOTING_CCNAME, u.ArrayToChaincodeArgs([]string{"getBallots", c.PRIMARY, "", "10"}), c.CHANNELID)
+	if response.Status == shim.OK {
+		test.Fatal("voting_cc called from a read only function")
+	}
+}
+
+func TestCandidateFreeze(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	late := stub.newUser(registrar, "SSN_1")
+	voter := stub.newUser(registrar, "SSN_2")
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	stub.registerVoter(c.PRIMARY, voter)
+
+	// @notice nominations close before the candidate list is frozen
+	stub.registerElection(c.GENERAL)
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_18", "setNominationRules", c.GENERAL, "1", getStartDate())
+	stub.asRole(c.OFFICIAL).mustInvoke("setNominationRules", c.GENERAL, "1", getDate(1))
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	// @notice from the start date on, candidates and their keys are frozen
+	stub.asRole(c.VOTER).expectError("VOT_ERR_58", "registerCandidate", late.sign(test, "registerCandidate", c.PRIMARY, late.Account)...)
+
+	keys := generateKeys(test)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_58", "rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_58", "recoverKey", candidate.SSN, keys.PublicKey)
+	stub.asRole(c.VOTER).mustInvoke("rotateKey", late.sign(test, "rotateKey", late.Account, keys.PublicKey)...)
+
+	stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, candidate.Account)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+	stub.asRole(c.VOTER).expectError("VOT_ERR_58", "rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Votes[candidate.Account] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+
+	// @notice once counted the candidate rotates again
+	keys = generateKeys(test)
+	stub.asRole(c.VOTER).mustInvoke("rotateKey", candidate.sign(test, "rotateKey", candidate.Account, keys.PublicKey)...)
+}
+
+func TestRingBallot(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	other := stub.newUser(registrar, "SSN_1")
+	voter := stub.newUser(registrar, "SSN_2")
+	member := stub.newUser(registrar, "SSN_3")
+
+	stub.registerElection(c.GENERAL, c.RING)
+	stub.registerCandidate(c.GENERAL, candidate)
+	stub.registerCandidate(c.GENERAL, other)
+
+	rotated := stub.newUser(registrar, "SSN_4")
+
+	for _, user := range []testUser{voter, member, rotated} {
+		stub.registerVoter(c.GENERAL, user)
+		stub.asRole(c.VOTER).mustInvoke("joinRing", user.sign(test, "joinRing", c.GENERAL, user.Account)...)
+	}
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_10", "joinRing", member.sign(test, "joinRing", c.GENERAL, member.Account)...)
+
+	ring := []string{}
+	stub.unmarshal(stub.as(testMSP, "anonymous", "").mustInvoke("getRing", c.GENERAL), &ring)
+	if len(ring) != 3 {
+		test.Fatalf("unexpected ring %v", ring)
+	}
+
+	// @notice a new key replaces the ring entry of the voter until the ring freezes
+	keys := generateKeys(test)
+	newUser := User{}
+	stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("rotateKey", rotated.sign(test, "rotateKey", rotated.Account, keys.PublicKey)...), &newUser)
+	rotated = testUser{rotated.SSN, newUser.PublicKey, keys.PrivateKey}
+	stub.asRole(c.VOTER).expectError("VOT_ERR_10", "joinRing", rotated.sign(test, "joinRing", c.GENERAL, rotated.Account)...)
+
+	previous := strings.Join(ring, ",")
+	stub.unmarshal(stub.as(testMSP, "anonymous", "").mustInvoke("getRing", c.GENERAL), &ring)
+	if len(ring) != 3 || strings.Join(ring, ",") == previous || !strings.Contains(strings.Join(ring, ","), keys.PublicKey) {
+		test.Fatalf("unexpected ring %v", ring)
+	}
+
+	stub.setElectionPeriod(c.GENERAL, getDate(0), getDate(1))
+
+	stub.registerVoter(c.GENERAL, other)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_33", "joinRing", other.sign(test, "joinRing", c.GENERAL, other.Account)...)
+	// @notice members of a frozen ring keep their key, a revoked key leaves the ring
+	recovered := generateKeys(test)
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_33", "recoverKey", member.SSN, recovered.PublicKey)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_33", "rotateKey", member.sign(test, "rotateKey", member.Account, recovered.PublicKey)...)
+
+	leaked, _ := a.SignRing(member.PrivateKey, ring, candidate.Account, getBallotContext(stub.getElectionRecord(c.GENERAL)))
+	leakedAsBytes, _ := json.Marshal(leaked)
+
+	stub.asRole(c.REGISTRAR).mustInvoke("revokeKey", member.Account, "leaked")
+	stub.unmarshal(stub.as(testMSP, "anonymous", "").mustInvoke("getRing", c.GENERAL), &ring)
+	if len(ring) != 2 {
+		test.Fatalf("unexpected ring %v", ring)
+	}
+
+	stub.as(testMSP, "anonymous", "").expectError("VOT_ERR_32", "castRingBallot", c.GENERAL, string(leakedAsBytes), candidate.Account)
+
+	signature, err := a.SignRing(voter.PrivateKey, ring, candidate.Account, getBallotContext(stub.getElectionRecord(c.GENERAL)))
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	signatureAsBytes, _ := json.Marshal(signature)
+
+	// @notice ring ballots are not cast with the certificate of a voter
+	stub.asRole(c.VOTER).expectError("VOT_ERR_19", "vote", string(signatureAsBytes), c.GENERAL, candidate.Account)
+	stub.as(testMSP, "anonymous", "").expectError("VOT_ERR_32", "castRingBallot", c.GENERAL, string(signatureAsBytes), other.Account)
+
+	vote := Vote{}
+	stub.unmarshal(stub.as(testMSP, "anonymous", "").mustInvoke("castRingBallot", c.GENERAL, string(signatureAsBytes), candidate.Account), &vote)
+	if vote.VoterSSN != "" || vote.KeyImage != signature.KeyImage || vote.Candidate != candidate.Account {
+		test.Fatalf("unexpected vote %+v", vote)
+	}
+
+	// @notice the key image links a second ballot of the same voter
+	stub.as(testMSP, "anonymous", "").expectError("ELECT_ERR_15", "castRingBallot", c.GENERAL, string(signatureAsBytes), candidate.Account)
+
+	stub.setElectionPeriod(c.GENERAL, getDate(-2), getDate(-1))
+	stub.as(testMSP, "anonymous", "").expectError("VOT_ERR_13", "castRingBallot", c.GENERAL, string(signatureAsBytes), candidate.Account)
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.GENERAL), &result)
+	if result.Total != 1 || result.Votes[candidate.Account] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestSealedBallot(test *testing.T) {
+	stub := newTestStub(test)
+	electStub := stub.peers[c.CCNAME]
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidates := []testUser{stub.newUser(registrar, "SSN_0"), stub.newUser(registrar, "SSN_1")}
+
+	privKey, pubKey, _ := a.GenerateElectionKeys()
+	otherKey, _, _ := a.GenerateElectionKeys()
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_21", "registerElection", c.PRIMARY, "primary2027", getStartDate(), getEndDate(), c.ECIES, "")
+	stub.registerElection(c.PRIMARY, c.ECIES, pubKey)
+
+	for _, candidate := range candidates {
+		stub.registerCandidate(c.PRIMARY, candidate)
+	}
+
+	voters := make([]testUser, 0)
+	for i := 2; i <= 5; i++ {
+		voter := stub.newUser(registrar, "SSN_"+strconv.Itoa(i))
+		stub.registerVoter(c.PRIMARY, voter)
+		voters = append(voters, voter)
+	}
+
+	context := getBallotContext(stub.getElectionRecord(c.PRIMARY))
+	seal := func(choice string) string {
+		test.Helper()
+
+		sealed, _, err := a.SealChoice(pubKey, choice, context)
+		if err != nil {
+			test.Fatal(err)
+		}
+
+		return sealed
+	}
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	stub.asRole(c.VOTER).expectError("ELECT_ERR_06", "vote", voters[0].SSN, c.PRIMARY, "not sealed")
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[0].SSN, c.PRIMARY, seal(candidates[0].Account))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[1].SSN, c.PRIMARY, seal(candidates[0].Account))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[2].SSN, c.PRIMARY, seal(candidates[1].Account))
+	stub.asRole(c.VOTER).mustInvoke("vote", voters[3].SSN, c.PRIMARY, seal("not a candidate"))
+
+	// @notice sealed ballots are not linked to the voter
+	for key := range electStub.State {
+		if strings.Contains(key, voters[0].SSN) {
+			test.Fatalf("ballot linked to the voter : %q", key)
+		}
+	}
+
+	authorityKey := map[string][]byte{c.AUTHORITY_KEY: []byte(privKey)}
+	stub.withTransient(authorityKey).asRole(c.OFFICIAL).expectError("VOT_ERR_17", "decryptAndTally", c.PRIMARY)
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+
+	stub.withTransient(nil).asRole(c.OFFICIAL).expectError("ELECT_ERR_18", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.withTransient(nil).asRole(c.OFFICIAL).expectError("ELECT_ERR_16", "decryptAndTally", c.PRIMARY)
+	stub.withTransient(map[string][]byte{c.AUTHORITY_KEY: []byte(otherKey)}).asRole(c.OFFICIAL).expectError("ELECT_ERR_16", "decryptAndTally", c.PRIMARY)
+	stub.withTransient(authorityKey).asRole(c.VOTER).expectError("ACC_ERR_01", "decryptAndTally", c.PRIMARY)
+
+	tally := elect_cc.SealedTally{}
+	stub.unmarshal(stub.withTransient(authorityKey).asRole(c.OFFICIAL).mustInvoke("decryptAndTally", c.PRIMARY, "true"), &tally)
+	if tally.Ballots != 4 || tally.Invalid != 1 || tally.Counts[candidates[0].Account] != 2 || tally.Counts[candidates[1].Account] != 1 || len(tally.Plaintexts) != 3 {
+		test.Fatalf("unexpected tally %+v", tally)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("ELECT_ERR_17", "decryptAndTally", c.PRIMARY)
+
+	// @notice the authority key is never written to the ledger
+	for key, value := range electStub.State {
+		if strings.Contains(string(value), privKey) {
+			test.Fatalf("authority key stored under %q", key)
+		}
+	}
+
+	result := elect_cc.VotingResult{}
+	stub.unmarshal(stub.withTransient(nil).asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY), &result)
+	if result.Total != 3 || result.Votes[candidates[0].Account] != 2 || result.Votes[candidates[1].Account] != 1 {
+		test.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestBallotLog(test *testing.T) {
+	stub := newTestStub(test)
+	electStub := stub.peers[c.CCNAME]
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+
+	voters := make([]testUser, 0)
+	for i := 1; i <= 6; i++ {
+		voter := stub.newUser(registrar, "SSN_"+strconv.Itoa(i))
+		stub.registerVoter(c.PRIMARY, voter)
+		voters = append(voters, voter)
+	}
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	receipts := make([]Vote, 0)
+	for _, voter := range voters {
+		vote := Vote{}
+		stub.unmarshal(stub.asRole(c.VOTER).mustInvoke("vote", voter.SSN, c.PRIMARY, candidate.Account), &vote)
+		receipts = append(receipts, vote)
+	}
+
+	stub.expectError("ELECT_ERR_12", "getInclusionProof", c.PRIMARY, "0")
+
+	stub.setElectionPeriod(c.PRIMARY, getDate(-2), getDate(-1))
+	stub.asRole(c.OFFICIAL).mustInvoke("countVotes", c.PLURALITY, c.PRIMARY)
+
+	// @notice the inner nodes are stored when the log is closed, 3 + 2 + 1 for 6 leaves
+	nodes, _ := u.GetAllCompositeKeys(electStub, c.BALLOT_NODE, []string{c.PRIMARY})
+	if len(nodes) != 6 {
+		test.Fatalf("unexpected inner nodes %d", len(nodes))
+	}
+
+	checkProofs := func() {
+		test.Helper()
+
+		for i, receipt := range receipts {
+			proof := elect_cc.InclusionProof{}
+			stub.unmarshal(stub.mustInvoke("getInclusionProof", c.PRIMARY, strconv.Itoa(receipt.LeafIndex)), &proof)
+
+			hash, _ := hex.DecodeString(proof.LeafHash)
+			root, _ := hex.DecodeString(proof.Root)
+			path := make([][]byte, 0)
+			for _, node := range proof.AuditPath {
+				sibling, _ := hex.DecodeString(node)
+				path = append(path, sibling)
+			}
+
+			if proof.LeafHash != receipt.LeafHash || proof.TreeSize != len(receipts) || !merkle.VerifyInclusion(hash, proof.LeafIndex, proof.TreeSize, path, root) {
+				test.Fatalf("invalid proof of leaf %d : %+v", i, proof)
+			}
+
+			verification := elect_cc.VoteVerification{}
+			stub.unmarshal(stub.mustInvoke("verifyMyVote", c.PRIMARY, strconv.Itoa(receipt.LeafIndex), receipt.LeafHash), &verification)
+			if !verification.Included || !verification.Counted || verification.CountedAs != candidate.Account {
+				test.Fatalf("unexpected verification of leaf %d : %+v", i, verification)
+			}
+		}
+	}
+
+	checkProofs()
+
+	stub.expectError("ELECT_ERR_13", "getInclusionProof", c.PRIMARY, "6")
+
+	verification := elect_cc.VoteVerification{}
+	stub.unmarshal(stub.mustInvoke("verifyMyVote", c.PRIMARY, "0", receipts[1].LeafHash), &verification)
+	if verification.Exists || verification.Included {
+		test.Fatalf("unexpected verification %+v", verification)
+	}
+
+	// @notice logs closed without inner nodes are rebuilt from their leaves
+	electStub.MockTransactionStart("legacy")
+	for _, node := range nodes {
+		electStub.DelState(node)
+	}
+
+	logKey, _ := electStub.CreateCompositeKey(c.BALLOT_LOG, []string{c.PRIMARY})
+	ballotLog := elect_cc.BallotLog{}
+	stub.unmarshal(electStub.State[logKey], &ballotLog)
+	ballotLog.Height = 0
+	logAsBytes, _ := json.Marshal(ballotLog)
+	electStub.PutState(logKey, logAsBytes)
+	electStub.MockTransactionEnd("legacy")
+
+	checkProofs()
+}
+
+func TestPolicies(test *testing.T) {
+	stub := newTestStub(test)
+
+	region := `[{"Attribute": "voting.region", "Values": ["north"]}]`
+
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_01", "setPolicy", "registerElection", c.ANY_ELECTION, region)
+	stub.asRole(c.ADMIN).expectError("COM_ERR_11", "setPolicy", "unknownFunction", c.ANY_ELECTION, region)
+	stub.asRole(c.ADMIN).expectError("VOT_ERR_04", "setPolicy", "registerElection", "national", region)
+	stub.asRole(c.ADMIN).expectError("ACC_ERR_05", "setPolicy", "registerElection", c.ANY_ELECTION, `[{"Attribute": "voting.region"}]`)
+	stub.asRole(c.ADMIN).expectError("ACC_ERR_05", "setPolicy", "registerElection", c.ANY_ELECTION, `[{"Attribute": "voting.region", "ElectionField": "Unknown"}]`)
+
+	stub.at(time.Date(2026, 4, 1, 10, 30, 0, 0, time.UTC)).asRole(c.ADMIN).mustInvoke("setPolicy", "registerElection", c.ANY_ELECTION, region)
+
+	policy := Policy{}
+	stub.unmarshal(stub.as(testMSP, "anyone", "").mustInvoke("getPolicy", "registerElection", c.ANY_ELECTION), &policy)
+	if len(policy.Rules) != 1 || policy.Rules[0].Attribute != "voting.region" || policy.UpdateDate != "2026/04/01 10:30:00" {
+		test.Fatalf("unexpected policy %+v", policy)
+	}
+
+	// @notice the role is still required, the policy narrows it down
+	args := []string{c.PRIMARY, "primary2027", getStartDate(), getEndDate()}
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_04", "registerElection", args...)
+	stub.as(testMSP, "official", c.OFFICIAL, "voting.region", "south").expectError("ACC_ERR_04", "registerElection", args...)
+	stub.as(testMSP, "voter", c.VOTER, "voting.region", "north").expectError("ACC_ERR_01", "registerElection", args...)
+	stub.as(testMSP, "official", c.OFFICIAL, "voting.region", "north").mustInvoke("registerElection", args...)
+	stub.asRole(c.ADMIN).mustInvoke("registerElection", c.GENERAL, "general2027", getStartDate(), getEndDate())
+
+	// @notice rules on election fields only apply to the election type of
+	// the policy, the calls that pass it fail on the election period
+	stub.asRole(c.ADMIN).mustInvoke("setPolicy", "countVotes", c.PRIMARY, `[{"Attribute": "voting.election", "ElectionField": "ID"}]`)
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_04", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.as(testMSP, "official", c.OFFICIAL, "voting.election", "general2027").expectError("ACC_ERR_04", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.as(testMSP, "official", c.OFFICIAL, "voting.election", "primary2027").expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.GENERAL)
+
+	// @notice an empty rule list removes the policy
+	stub.asRole(c.ADMIN).mustInvoke("setPolicy", "countVotes", c.PRIMARY, "[]")
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.PRIMARY)
+}
+
+func TestIdentityBinding(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	unbound := stub.newUser(registrar, "SSN_1")
+
+	newUser := NewUser{}
+	stub.unmarshal(stub.as(testMSP, "alice", c.VOTER).mustInvoke("registerUser", "SSN_A", "Alice", "Last", "1980/01/01", "F", "", "true"), &newUser)
+	alice := testUser{newUser.SSN, newUser.PublicKey, newUser.PrivateKey}
+
+	if user := stub.getUser(alice.SSN); user.MSPID != testMSP || !strings.Contains(user.Subject, "alice") {
+		test.Fatalf("unexpected user %+v", user)
+	}
+
+	// @notice one user per identity, the MSP ID is part of the identity
+	stub.as(testMSP, "alice", c.VOTER).expectError("ACC_ERR_06", "registerUser", "SSN_B", "Other", "Last", "1980/01/01", "F", "", "true")
+	stub.as("Org2MSP", "alice", c.VOTER).mustInvoke("registerUser", "SSN_B", "Other", "Last", "1980/01/01", "F", "", "true")
+
+	stub.verify(registrar, alice)
+	stub.registerElection(c.PRIMARY)
+	stub.registerCandidate(c.PRIMARY, candidate)
+	stub.registerVoter(c.PRIMARY, alice)
+	stub.registerVoter(c.PRIMARY, unbound)
+	stub.setElectionPeriod(c.PRIMARY, getDate(0), getDate(1))
+
+	// @notice a bound voter only votes from the own identity
+	stub.as(testMSP, "bob", c.VOTER).expectError("ACC_ERR_07", "vote", alice.SSN, c.PRIMARY, candidate.Account)
+	stub.as("Org2MSP", "alice", c.VOTER).expectError("ACC_ERR_07", "vote", alice.SSN, c.PRIMARY, candidate.Account)
+	stub.as(testMSP, "alice", c.VOTER).mustInvoke("vote", alice.SSN, c.PRIMARY, candidate.Account)
+
+	stub.as(testMSP, "bob", c.VOTER).mustInvoke("vote", unbound.SSN, c.PRIMARY, candidate.Account)
+}
+
+func TestElectionProposal(test *testing.T) {
+	stub := newTestStub(test)
+
+	stub.asRole(c.ADMIN).expectError("VOT_ERR_37", "setElectionQuorum", "0")
+	stub.asRole(c.ADMIN).mustInvoke("setElectionQuorum", "2")
+
+	newElection := NewElection{}
+	stub.unmarshal(stub.as(testMSP, "official1", c.OFFICIAL).mustInvoke("registerElection", c.PRIMARY, "primary2027", getStartDate(), getEndDate()), &newElection)
+	if newElection.Status != c.PENDING {
+		test.Fatalf("unexpected election %+v", newElection)
+	}
+
+	pending := []ElectionProposal{}
+	stub.unmarshal(stub.mustInvoke("getPendingElections"), &pending)
+	if len(pending) != 1 || pending[0].Quorum != 2 || pending[0].ExpiryDate != getDate(c.PROPOSAL_DAYS) {
+		test.Fatalf("unexpected proposals %+v", pending)
+	}
+
+	// @notice the election is not registered until the quorum is reached
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_15", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.as(testMSP, "official2", c.OFFICIAL).expectError("VOT_ERR_36", "approveElection", c.PRIMARY, "primary2027")
+	stub.as("Org2MSP", "official", c.OFFICIAL).expectError("VOT_ERR_34", "approveElection", c.PRIMARY, "unknown")
+	stub.as("Org2MSP", "voter", c.VOTER).expectError("ACC_ERR_01", "approveElection", c.PRIMARY, "primary2027")
+
+	approvedAt := time.Now().UTC().AddDate(0, 0, 1).Truncate(time.Hour)
+
+	proposal := ElectionProposal{}
+	stub.unmarshal(stub.at(approvedAt).as("Org2MSP", "official", c.OFFICIAL).mustInvoke("approveElection", c.PRIMARY, "primary2027"), &proposal)
+	if proposal.Status != c.ACTIVE || len(proposal.Approvals) != 2 || proposal.Approvals[1].ApprovalDate != approvedAt.Format("2006/01/02 15:04:05") {
+		test.Fatalf("unexpected proposal %+v", proposal)
+	}
+
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.PRIMARY)
+	stub.as("Org3MSP", "official", c.OFFICIAL).expectError("VOT_ERR_35", "approveElection", c.PRIMARY, "primary2027")
+
+	// @notice proposals expire, and can be proposed again once expired
+	stub.as(testMSP, "official1", c.OFFICIAL).mustInvoke("registerElection", c.GENERAL, "general2027", getStartDate(), getEndDate())
+	stub.as(testMSP, "official1", c.OFFICIAL).expectError("VOT_ERR_35", "registerElection", c.GENERAL, "general2027", getStartDate(), getEndDate())
+
+	proposalKey, _ := stub.CreateCompositeKey(c.ELECTION_PROPOSAL, []string{c.GENERAL, "general2027"})
+	stub.unmarshal(stub.State[proposalKey], &proposal)
+	proposal.ExpiryDate = getDate(-1)
+	proposalAsBytes, _ := json.Marshal(proposal)
+	stub.putState(proposalKey, proposalAsBytes)
+
+	stub.as("Org2MSP", "official", c.OFFICIAL).expectError("VOT_ERR_35", "approveElection", c.GENERAL, "general2027")
+
+	stub.unmarshal(stub.mustInvoke("getPendingElections"), &pending)
+	if len(pending) != 0 {
+		test.Fatalf("unexpected proposals %+v", pending)
+	}
+
+	stub.as(testMSP, "official1", c.OFFICIAL).mustInvoke("registerElection", c.GENERAL, "general2027", getStartDate(), getEndDate())
+	stub.as("Org2MSP", "official", c.OFFICIAL).mustInvoke("approveElection", c.GENERAL, "general2027")
+	stub.as("Org2MSP", "official", c.OFFICIAL).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.GENERAL)
+}
+
+func (s *testStub) getEndorsingOrgs(electionType string) []string {
+	s.test.Helper()
+
+	orgs := []string{}
+	s.unmarshal(s.as(testMSP, "anyone", "").mustInvoke("getElectionEndorsement", electionType), &orgs)
+	sort.Strings(orgs)
+
+	return orgs
+}
+
+func TestElectionEndorsement(test *testing.T) {
+	stub := newTestStub(test)
+
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_01", "setAuditorOrgs", `["Org2MSP"]`)
+	stub.asRole(c.ADMIN).expectError("COM_ERR_02", "setAuditorOrgs", "Org2MSP")
+	stub.asRole(c.ADMIN).expectError("COM_ERR_18", "setAuditorOrgs", `["Org2MSP", ""]`)
+	stub.asRole(c.ADMIN).mustInvoke("setAuditorOrgs", `["Org2MSP", "Org1MSP"]`)
+
+	// @notice the owning organization endorses along with the auditor organizations
+	stub.registerElection(c.PRIMARY)
+	if orgs := stub.getEndorsingOrgs(c.PRIMARY); strings.Join(orgs, ",") != "Org1MSP,Org2MSP" {
+		test.Fatalf("unexpected endorsing organizations %v", orgs)
+	}
+
+	if election := stub.getElectionRecord(c.PRIMARY); election.OwnerMSP != testMSP || len(election.EndorsingOrgs) != 2 {
+		test.Fatalf("unexpected election %+v", election)
+	}
+
+	stub.asRole(c.AUDITOR).expectError("ACC_ERR_01", "setElectionEndorsement", c.PRIMARY, `["Org3MSP"]`)
+	stub.asRole(c.OFFICIAL).expectError("VOT_ERR_15", "setElectionEndorsement", c.GENERAL, `["Org3MSP"]`)
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_18", "setElectionEndorsement", c.PRIMARY, "[]")
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_02", "setElectionEndorsement", c.PRIMARY, "Org3MSP")
+	stub.asRole(c.OFFICIAL).mustInvoke("setElectionEndorsement", c.PRIMARY, `["Org3MSP"]`)
+
+	if orgs := stub.getEndorsingOrgs(c.PRIMARY); strings.Join(orgs, ",") != "Org3MSP" {
+		test.Fatalf("unexpected endorsing organizations %v", orgs)
+	}
+
+	stub.as(testMSP, "anyone", "").expectError("VOT_ERR_15", "getElectionEndorsement", c.GENERAL)
+}
+
+func TestJurisdiction(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	asOfficial := func(jurisdiction string) *testStub {
+		return stub.as(testMSP, "official", c.OFFICIAL, c.JURISDICTION_ATTRIBUTE, jurisdiction)
+	}
+
+	args := []string{c.LOCAL, "local2027", getStartDate(), getEndDate(), c.OPEN, ""}
+	asOfficial("springfield").expectError("VOT_ERR_39", "registerElection", args...)
+	asOfficial("springfield").expectError("ACC_ERR_10", "registerElection", c.PRIMARY, "primary2027", getStartDate(), getEndDate())
+	asOfficial("shelbyville").expectError("ACC_ERR_10", "registerElection", append(args, "springfield")...)
+	asOfficial("springfield").mustInvoke("registerElection", append(args, "springfield")...)
+
+	if election := stub.getElectionRecord(c.LOCAL); election.Jurisdiction != "springfield" {
+		test.Fatalf("unexpected election %+v", election)
+	}
+
+	// @notice national officials do not manage local elections, admins do.
+	// The calls that pass fail on the election period.
+	stub.asRole(c.OFFICIAL).expectError("ACC_ERR_10", "countVotes", c.PLURALITY, c.LOCAL)
+	asOfficial("shelbyville").expectError("ACC_ERR_10", "countVotes", c.PLURALITY, c.LOCAL)
+	asOfficial("springfield").expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.LOCAL)
+	stub.asRole(c.ADMIN).expectError("VOT_ERR_17", "countVotes", c.PLURALITY, c.LOCAL)
+
+	voter := stub.newUser(registrar, "SSN_1")
+
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_40", "registerVoter", voter.SSN, c.LOCAL)
+	stub.asRole(c.VOTER).expectError("ACC_ERR_01", "setResidence", voter.SSN, "springfield")
+	stub.asRole(c.REGISTRAR).expectError("COM_ERR_18", "setResidence", voter.SSN, "")
+	stub.asRole(c.REGISTRAR).expectError("COM_ERR_14", "setResidence", "SSN_X", "springfield")
+
+	stub.asRole(c.REGISTRAR).mustInvoke("setResidence", voter.SSN, "shelbyville")
+	stub.asRole(c.REGISTRAR).expectError("VOT_ERR_40", "registerVoter", voter.SSN, c.LOCAL)
+
+	stub.asRole(c.REGISTRAR).mustInvoke("setResidence", voter.SSN, "springfield")
+	stub.registerVoter(c.LOCAL, voter)
+
+	if user := stub.getUser(voter.SSN); user.Residence != "springfield" {
+		test.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestPetition(test *testing.T) {
+	stub := newTestStub(test)
+
+	registrar := stub.newRegistrar("SSN_R")
+	candidate := stub.newUser(registrar, "SSN_0")
+	late := stub.newUser(registrar, "SSN_1")
+	voters := []testUser{stub.newUser(registrar, "SSN_2"), stub.newUser(registrar, "SSN_3"), stub.newUser(registrar, "SSN_4")}
+
+	openPetition := func(user testUser) []string {
+		return user.sign(test, "openPetition", c.PRIMARY, user.Account)
+	}
+
+	endorsePetition := func(voter testUser, candidateSSN string) []string {
+		return voter.sign(test, "endorsePetition", c.PRIMARY, candidateSSN, voter.Account)
+	}
+
+	stub.registerElection(c.PRIMARY)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_48", "openPetition", openPetition(candidate)...)
+
+	stub.asRole(c.OFFICIAL).expectError("COM_ERR_18", "setNominationRules", c.PRIMARY, "2", getStartDate())
+	stub.asRole(c.OFFICIAL).mustInvoke("setNominationRules", c.PRIMARY, "2", getDate(1))
+	stub.asRole(c.VOTER).expectError("VOT_ERR_47", "registerCandidate", candidate.sign(test, "registerCandidate", c.PRIMARY, candidate.Account)...)
+
+	stub.registerVoter(c.PRIMARY, candidate)
+	stub.registerVoter(c.PRIMARY, voters[0])
+	stub.registerVoter(c.PRIMARY, voters[1])
+
+	stub.asRole(c.VOTER).mustInvoke("openPetition", openPetition(candidate)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_49", "openPetition", openPetition(candidate)...)
+
+	// @notice only registered voters endorse, once each and not their own petition
+	stub.asRole(c.VOTER).expectError("VOT_ERR_50", "endorsePetition", endorsePetition(voters[0], late.SSN)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_12", "endorsePetition", endorsePetition(candidate, candidate.SSN)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_11", "endorsePetition", endorsePetition(voters[2], candidate.SSN)...)
+	stub.asRole(c.VOTER).mustInvoke("endorsePetition", endorsePetition(voters[0], candidate.SSN)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_51", "endorsePetition", endorsePetition(voters[0], candidate.SSN)...)
+
+	if registered, _ := u.FindCompositeKey(stub, c.CANDIDATE, []string{c.PRIMARY, candidate.SSN}); registered != "" {
+		test.Fatal("candidate registered below the threshold")
+	}
+
+	endorsedAt := time.Now().UTC().Truncate(time.Hour)
+
+	petition := Petition{}
+	stub.unmarshal(stub.at(endorsedAt).asRole(c.VOTER).mustInvoke("endorsePetition", endorsePetition(voters[1], candidate.SSN)...), &petition)
+	if petition.Status != c.PETITION_APPROVED || petition.Endorsements != 2 {
+		test.Fatalf("unexpected petition %+v", petition)
+	}
+
+	endorsement := Endorsement{}
+	endorsementKey, _ := stub.CreateCompositeKey(c.ENDORSEMENT, []string{c.PRIMARY, candidate.SSN, voters[1].SSN})
+	stub.unmarshal(stub.State[endorsementKey], &endorsement)
+	if endorsement.EndorsementDate != endorsedAt.Format("2006/01/02 15:04:05") {
+		test.Fatalf("unexpected endorsement %+v", endorsement)
+	}
+
+	if registered, _ := u.FindCompositeKey(stub, c.CANDIDATE, []string{c.PRIMARY, candidate.SSN}); registered == "" {
+		test.Fatal("candidate not registered at the threshold")
+	}
+
+	stub.registerVoter(c.PRIMARY, voters[2])
+	stub.asRole(c.VOTER).expectError("VOT_ERR_49", "endorsePetition", endorsePetition(voters[2], candidate.SSN)...)
+
+	// @notice petitions expire with the nomination deadline
+	stub.asRole(c.VOTER).mustInvoke("openPetition", openPetition(voters[2])...)
+
+	election, _ := u.FindCompositeKey(stub, c.ELECTION, []string{c.PRIMARY})
+	electionInfo := stub.getElectionRecord(c.PRIMARY)
+	electionInfo.NominationDeadline = getDate(-1)
+	electionAsBytes, _ := json.Marshal(electionInfo)
+	stub.putState(election, electionAsBytes)
+
+	petitionKey, _ := stub.CreateCompositeKey(c.PETITION, []string{c.PRIMARY, voters[2].SSN})
+	stub.unmarshal(stub.State[petitionKey], &petition)
+	petition.Deadline = getDate(-1)
+	petitionAsBytes, _ := json.Marshal(petition)
+	stub.putState(petitionKey, petitionAsBytes)
+
+	stub.asRole(c.VOTER).expectError("VOT_ERR_13", "openPetition", openPetition(late)...)
+	stub.asRole(c.VOTER).expectError("VOT_ERR_49", "endorsePetition", endorsePetition(voters[0], voters[2].SSN)...)
+
+	stub.unmarshal(stub.as(testMSP, "anyone", "").mustInvoke("getPetition", c.PRIMARY, voters[2].SSN), &petition)
+	if petition.Status != c.EXPIRED || petition.Endorsements != 0 {
+		test.Fatalf("unexpected petition %+v", petition)
+	}
 }